@@ -0,0 +1,146 @@
+package orderv1
+
+import (
+	_ "embed"
+	"fmt"
+	"time"
+
+	"order-service/internal/domain/entities"
+
+	"github.com/google/uuid"
+)
+
+// AvroSchema is the Avro counterpart of order.proto's Order.
+//
+//go:embed order.avsc
+var AvroSchema string
+
+// ProtoSchema is order.proto as registered with a schema registry.
+//
+//go:embed order.proto
+var ProtoSchema string
+
+type AvroOrder struct {
+	OrderUid          string       `avro:"order_uid"`
+	TrackNumber       string       `avro:"track_number"`
+	Entry             string       `avro:"entry"`
+	Locale            string       `avro:"locale"`
+	InternalSignature string       `avro:"internal_signature"`
+	CustomerId        string       `avro:"customer_id"`
+	DeliveryService   string       `avro:"delivery_service"`
+	Shardkey          int64        `avro:"shardkey"`
+	SmId              int64        `avro:"sm_id"`
+	DateCreated       time.Time    `avro:"date_created"`
+	Delivery          AvroDelivery `avro:"delivery"`
+	Payment           AvroPayment  `avro:"payment"`
+	Items             []AvroItem   `avro:"items"`
+}
+
+type AvroDelivery struct {
+	Name    string `avro:"name"`
+	Phone   string `avro:"phone"`
+	Zip     string `avro:"zip"`
+	City    string `avro:"city"`
+	Address string `avro:"address"`
+	Region  string `avro:"region"`
+	Email   string `avro:"email"`
+}
+
+type AvroPayment struct {
+	TransactionId string `avro:"transaction_id"`
+	RequestId     string `avro:"request_id"`
+	Currency      string `avro:"currency"`
+	Provider      string `avro:"provider"`
+	Amount        int64  `avro:"amount"`
+	PaymentDt     int64  `avro:"payment_dt"`
+	Bank          string `avro:"bank"`
+	DeliveryCost  int64  `avro:"delivery_cost"`
+	GoodsTotal    int64  `avro:"goods_total"`
+	CustomFee     int64  `avro:"custom_fee"`
+}
+
+type AvroItem struct {
+	ChrtId      int64  `avro:"chrt_id"`
+	TrackNumber string `avro:"track_number"`
+	Price       int64  `avro:"price"`
+	Rid         string `avro:"rid"`
+	Name        string `avro:"name"`
+	Sale        int64  `avro:"sale"`
+	Size        string `avro:"size"`
+	TotalPrice  int64  `avro:"total_price"`
+	NmId        int64  `avro:"nm_id"`
+	Brand       string `avro:"brand"`
+	Status      int64  `avro:"status"`
+}
+
+func AvroFromEntity(o *entities.Order) *AvroOrder {
+	items := make([]AvroItem, 0, len(o.Items))
+	for _, it := range o.Items {
+		items = append(items, AvroItem{
+			ChrtId:      it.ChrtId,
+			TrackNumber: it.TrackNumber,
+			Price:       it.Price,
+			Rid:         it.RID,
+			Name:        it.Name,
+			Sale:        int64(it.Sale),
+			Size:        it.Size,
+			TotalPrice:  it.TotalPrice,
+			NmId:        it.NmID,
+			Brand:       it.Brand,
+			Status:      int64(it.Status),
+		})
+	}
+	return &AvroOrder{
+		OrderUid:          o.OrderId.String(),
+		TrackNumber:       o.TrackNumber,
+		Entry:             o.Entry,
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSignature,
+		CustomerId:        o.CustomerId,
+		DeliveryService:   o.DeliveryService,
+		Shardkey:          o.ShardKey,
+		SmId:              int64(o.SmId),
+		DateCreated:       o.DateCreated,
+		Delivery:          AvroDelivery(o.Delivery),
+		Payment:           AvroPayment(o.Payment),
+		Items:             items,
+	}
+}
+
+func (a *AvroOrder) ToEntity() (*entities.Order, error) {
+	id, err := uuid.Parse(a.OrderUid)
+	if err != nil {
+		return nil, fmt.Errorf("order_uid: %w", err)
+	}
+	o := &entities.Order{
+		OrderId:           id,
+		TrackNumber:       a.TrackNumber,
+		Entry:             a.Entry,
+		Locale:            a.Locale,
+		InternalSignature: a.InternalSignature,
+		CustomerId:        a.CustomerId,
+		DeliveryService:   a.DeliveryService,
+		ShardKey:          a.Shardkey,
+		SmId:              int(a.SmId),
+		DateCreated:       a.DateCreated,
+		Delivery:          entities.Delivery(a.Delivery),
+		Payment:           entities.Payment(a.Payment),
+		Items:             make([]entities.Item, 0, len(a.Items)),
+	}
+	for _, it := range a.Items {
+		o.Items = append(o.Items, entities.Item{
+			ChrtId:      it.ChrtId,
+			TrackNumber: it.TrackNumber,
+			Price:       it.Price,
+			RID:         it.Rid,
+			Name:        it.Name,
+			Sale:        int(it.Sale),
+			Size:        it.Size,
+			TotalPrice:  it.TotalPrice,
+			NmID:        it.NmId,
+			Brand:       it.Brand,
+			Status:      int(it.Status),
+		})
+	}
+	return o, nil
+}
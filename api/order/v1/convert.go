@@ -0,0 +1,126 @@
+package orderv1
+
+import (
+	"fmt"
+
+	"order-service/internal/domain/entities"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func FromEntity(o *entities.Order) *Order {
+	items := make([]*Item, 0, len(o.Items))
+	for _, it := range o.Items {
+		items = append(items, &Item{
+			ChrtId:      it.ChrtId,
+			TrackNumber: it.TrackNumber,
+			Price:       it.Price,
+			Rid:         it.RID,
+			Name:        it.Name,
+			Sale:        int64(it.Sale),
+			Size:        it.Size,
+			TotalPrice:  it.TotalPrice,
+			NmId:        it.NmID,
+			Brand:       it.Brand,
+			Status:      int64(it.Status),
+		})
+	}
+	return &Order{
+		OrderUid:          o.OrderId.String(),
+		TrackNumber:       o.TrackNumber,
+		Entry:             o.Entry,
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSignature,
+		CustomerId:        o.CustomerId,
+		DeliveryService:   o.DeliveryService,
+		Shardkey:          o.ShardKey,
+		SmId:              int64(o.SmId),
+		DateCreated:       timestamppb.New(o.DateCreated),
+		Delivery: &Delivery{
+			Name:    o.Delivery.Name,
+			Phone:   o.Delivery.Phone,
+			Zip:     o.Delivery.Zip,
+			City:    o.Delivery.City,
+			Address: o.Delivery.Address,
+			Region:  o.Delivery.Region,
+			Email:   o.Delivery.Email,
+		},
+		Payment: &Payment{
+			TransactionId: o.Payment.TransactionId,
+			RequestId:     o.Payment.RequestId,
+			Currency:      o.Payment.Currency,
+			Provider:      o.Payment.Provider,
+			Amount:        o.Payment.Amount,
+			PaymentDt:     o.Payment.PaymentDt,
+			Bank:          o.Payment.Bank,
+			DeliveryCost:  o.Payment.DeliveryCost,
+			GoodsTotal:    o.Payment.GoodsTotal,
+			CustomFee:     o.Payment.CustomFee,
+		},
+		Items: items,
+	}
+}
+
+func ToEntity(m *Order) (*entities.Order, error) {
+	id, err := uuid.Parse(m.GetOrderUid())
+	if err != nil {
+		return nil, fmt.Errorf("order_uid: %w", err)
+	}
+	o := &entities.Order{
+		OrderId:           id,
+		TrackNumber:       m.GetTrackNumber(),
+		Entry:             m.GetEntry(),
+		Locale:            m.GetLocale(),
+		InternalSignature: m.GetInternalSignature(),
+		CustomerId:        m.GetCustomerId(),
+		DeliveryService:   m.GetDeliveryService(),
+		ShardKey:          m.GetShardkey(),
+		SmId:              int(m.GetSmId()),
+		Items:             make([]entities.Item, 0, len(m.GetItems())),
+	}
+	if m.DateCreated != nil {
+		o.DateCreated = m.DateCreated.AsTime()
+	}
+	if d := m.GetDelivery(); d != nil {
+		o.Delivery = entities.Delivery{
+			Name:    d.GetName(),
+			Phone:   d.GetPhone(),
+			Zip:     d.GetZip(),
+			City:    d.GetCity(),
+			Address: d.GetAddress(),
+			Region:  d.GetRegion(),
+			Email:   d.GetEmail(),
+		}
+	}
+	if p := m.GetPayment(); p != nil {
+		o.Payment = entities.Payment{
+			TransactionId: p.GetTransactionId(),
+			RequestId:     p.GetRequestId(),
+			Currency:      p.GetCurrency(),
+			Provider:      p.GetProvider(),
+			Amount:        p.GetAmount(),
+			PaymentDt:     p.GetPaymentDt(),
+			Bank:          p.GetBank(),
+			DeliveryCost:  p.GetDeliveryCost(),
+			GoodsTotal:    p.GetGoodsTotal(),
+			CustomFee:     p.GetCustomFee(),
+		}
+	}
+	for _, it := range m.GetItems() {
+		o.Items = append(o.Items, entities.Item{
+			ChrtId:      it.GetChrtId(),
+			TrackNumber: it.GetTrackNumber(),
+			Price:       it.GetPrice(),
+			RID:         it.GetRid(),
+			Name:        it.GetName(),
+			Sale:        int(it.GetSale()),
+			Size:        it.GetSize(),
+			TotalPrice:  it.GetTotalPrice(),
+			NmID:        it.GetNmId(),
+			Brand:       it.GetBrand(),
+			Status:      int(it.GetStatus()),
+		})
+	}
+	return o, nil
+}
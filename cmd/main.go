@@ -1,7 +1,19 @@
 package main
 
-import "order-service/internal/app"
+import (
+	"fmt"
+	"os"
+
+	"order-service/internal/app"
+)
 
 func main() {
+	if len(os.Args) > 1 {
+		if err := app.Command(os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 	app.New().Run()
 }
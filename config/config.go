@@ -18,6 +18,11 @@ func NewConfig() (*ConfigModel, error) {
 		addr = ":8081"
 	}
 	c.HTTP.Addr = addr
+	c.HTTP.ValidateResponses = boolDefault("HTTP_VALIDATE_RESPONSES", false)
+	c.HTTP.CacheMaxAge = atoiDefault("HTTP_CACHE_MAX_AGE", 0)
+	c.HTTP.BatchGetMax = atoiDefault("HTTP_BATCH_GET_MAX", 500)
+
+	c.GRPC.Addr = getenvDefault("GRPC_ADDR", ":9090")
 
 	c.Postgres.DSN = os.Getenv("PG_DSN")
 	if c.Postgres.DSN == "" {
@@ -35,6 +40,32 @@ func NewConfig() (*ConfigModel, error) {
 	if c.Kafka.GroupID = os.Getenv("KAFKA_GROUP_ID"); c.Kafka.GroupID == "" {
 		c.Kafka.GroupID = "orders-group"
 	}
+	c.Kafka.BatchSize = atoiDefault("KAFKA_BATCH_SIZE", 1)
+	c.Kafka.BatchWaitMs = atoiDefault("KAFKA_BATCH_WAIT_MS", 100)
+	c.Kafka.Workers = atoiDefault("KAFKA_WORKERS", 0)
+	c.Kafka.LagIntervalSeconds = atoiDefault("KAFKA_LAG_INTERVAL_SECONDS", 15)
+	c.Kafka.LagThreshold = int64(atoiDefault("KAFKA_LAG_THRESHOLD", 0))
+	c.Kafka.LagSustainSeconds = atoiDefault("KAFKA_LAG_SUSTAIN_SECONDS", 300)
+	c.Kafka.Subscriptions = parseSubscriptions(os.Getenv("KAFKA_SUBSCRIPTIONS"), KafkaSubscription{
+		Topic:   c.Kafka.Topic,
+		Group:   c.Kafka.GroupID,
+		Decoder: "json",
+		Workers: c.Kafka.Workers,
+	})
+	c.Kafka.JSONStrict = getenvDefault("KAFKA_JSON_STRICT", "off")
+	c.Kafka.DLQTopic = os.Getenv("KAFKA_DLQ_TOPIC")
+	c.Kafka.ProducerFormat = getenvDefault("KAFKA_PRODUCER_FORMAT", "json")
+	c.Kafka.TLS.Enabled = boolDefault("KAFKA_TLS_ENABLED", false)
+	c.Kafka.TLS.CAFile = os.Getenv("KAFKA_TLS_CA_FILE")
+	c.Kafka.TLS.CertFile = os.Getenv("KAFKA_TLS_CERT_FILE")
+	c.Kafka.TLS.KeyFile = os.Getenv("KAFKA_TLS_KEY_FILE")
+	c.Kafka.TLS.InsecureSkipVerify = boolDefault("KAFKA_TLS_INSECURE_SKIP_VERIFY", false)
+	c.Kafka.SASL.Mechanism = os.Getenv("KAFKA_SASL_MECHANISM")
+	c.Kafka.SASL.Username = os.Getenv("KAFKA_SASL_USERNAME")
+	c.Kafka.SASL.Password = os.Getenv("KAFKA_SASL_PASSWORD")
+
+	c.SchemaRegistry.URL = os.Getenv("SCHEMA_REGISTRY_URL")
+	c.SchemaRegistry.TimeoutMs = atoiDefault("SCHEMA_REGISTRY_TIMEOUT_MS", 5000)
 
 	c.Redis.Addr = getenvDefault("REDIS_ADDR", "localhost:6379")
 	c.Redis.Password = os.Getenv("REDIS_PASSWORD")
@@ -42,6 +73,36 @@ func NewConfig() (*ConfigModel, error) {
 	c.Redis.TTLSeconds = atoiDefault("REDIS_TTL_SECONDS", 0)
 	c.Redis.KeyPrefix = getenvDefault("REDIS_KEY_PREFIX", "orders:")
 
+	c.Auth.Enabled = boolDefault("AUTH_ENABLED", false)
+	c.Auth.APIKeys = parseAPIKeys(os.Getenv("AUTH_API_KEYS"))
+	c.Auth.JWTSecret = os.Getenv("AUTH_JWT_SECRET")
+	c.Auth.JWKSFile = os.Getenv("AUTH_JWKS_FILE")
+	c.Auth.JWTIssuer = os.Getenv("AUTH_JWT_ISSUER")
+	c.Auth.JWTAudience = os.Getenv("AUTH_JWT_AUDIENCE")
+	c.Auth.JWTRolesClaim = getenvDefault("AUTH_JWT_ROLES_CLAIM", "roles")
+
+	c.PII.KeyFile = os.Getenv("PII_KEY_FILE")
+
+	c.Masking.Rules = parseMaskRules(getenvDefault("PII_MASK_RULES",
+		"admin=*:none;support=*:partial;reader=*:redact;ingest=*:redact"))
+	c.Masking.DefaultRole = getenvDefault("PII_MASK_DEFAULT_ROLE", "admin")
+
+	c.RateLimit.Enabled = boolDefault("RATE_LIMIT_ENABLED", true)
+	c.RateLimit.Rules = parseRateRules(getenvDefault("RATE_LIMIT_RULES",
+		"default=50/s:100;GET /order/{uid}=20/s:40;POST /orders/batch-get=2/s:5;GET /orders/export=1/m:2;POST /graphql=10/s:20;GET /orders/stream=1/s:5;GET /order/{uid}/watch=1/s:5"))
+	c.RateLimit.KeyPrefix = getenvDefault("RATE_LIMIT_KEY_PREFIX", "ratelimit:")
+	c.RateLimit.TrustProxy = boolDefault("RATE_LIMIT_TRUST_PROXY", false)
+
+	c.Health.Critical = splitAndTrim(getenvDefault("HEALTH_CRITICAL", "postgres"))
+	c.Health.TimeoutMs = atoiDefault("HEALTH_TIMEOUT_MS", 2000)
+
+	c.Stream.BufferSize = atoiDefault("STREAM_BUFFER_SIZE", 1000)
+	c.Stream.HeartbeatSeconds = atoiDefault("STREAM_HEARTBEAT_SECONDS", 15)
+
+	c.WebSocket.MaxConnections = atoiDefault("WS_MAX_CONNECTIONS", 1000)
+	c.WebSocket.WriteTimeoutSeconds = atoiDefault("WS_WRITE_TIMEOUT_SECONDS", 10)
+	c.WebSocket.PingSeconds = atoiDefault("WS_PING_SECONDS", 30)
+
 	return c, nil
 }
 
@@ -57,6 +118,87 @@ func splitAndTrim(s string) []string {
 	return out
 }
 
+// parseAPIKeys reads "key1:reader,key2:admin|ingest".
+func parseAPIKeys(s string) map[string][]string {
+	out := map[string][]string{}
+	for _, entry := range splitAndTrim(s) {
+		key, roles, ok := strings.Cut(entry, ":")
+		if !ok || key == "" {
+			continue
+		}
+		for _, r := range strings.Split(roles, "|") {
+			if r = strings.TrimSpace(r); r != "" {
+				out[key] = append(out[key], r)
+			}
+		}
+	}
+	return out
+}
+
+// parseMaskRules reads "role=field:mode,field:mode;role=...".
+func parseMaskRules(s string) map[string]string {
+	out := map[string]string{}
+	for _, entry := range strings.Split(s, ";") {
+		role, spec, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || role == "" {
+			continue
+		}
+		out[strings.TrimSpace(role)] = spec
+	}
+	return out
+}
+
+// parseRateRules reads "route=rule;route=rule". Routes contain spaces and
+// slashes but never "=" or ";".
+func parseRateRules(s string) map[string]string {
+	out := map[string]string{}
+	for _, entry := range strings.Split(s, ";") {
+		route, rule, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || route == "" {
+			continue
+		}
+		out[strings.TrimSpace(route)] = strings.TrimSpace(rule)
+	}
+	return out
+}
+
+// parseSubscriptions reads "topic=decoder:json,group:g,workers:4;topic=...".
+// Options left out are taken from def, except that topics other than
+// def.Topic get a group of their own, def.Group-topic. An empty s yields
+// def alone.
+func parseSubscriptions(s string, def KafkaSubscription) []KafkaSubscription {
+	var out []KafkaSubscription
+	for _, entry := range strings.Split(s, ";") {
+		topic, opts, _ := strings.Cut(strings.TrimSpace(entry), "=")
+		if topic = strings.TrimSpace(topic); topic == "" {
+			continue
+		}
+		sub := def
+		sub.Topic = topic
+		if topic != def.Topic {
+			sub.Group = def.Group + "-" + topic
+		}
+		for _, opt := range splitAndTrim(opts) {
+			k, v, _ := strings.Cut(opt, ":")
+			switch v = strings.TrimSpace(v); strings.TrimSpace(k) {
+			case "decoder":
+				sub.Decoder = v
+			case "group":
+				sub.Group = v
+			case "workers":
+				if n, err := strconv.Atoi(v); err == nil {
+					sub.Workers = n
+				}
+			}
+		}
+		out = append(out, sub)
+	}
+	if len(out) == 0 {
+		out = append(out, def)
+	}
+	return out
+}
+
 func getenvDefault(key, def string) string {
 	v := os.Getenv(key)
 	if v == "" {
@@ -76,3 +218,15 @@ func atoiDefault(key string, def int) int {
 	}
 	return n
 }
+
+func boolDefault(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def
+	}
+	return b
+}
@@ -3,6 +3,16 @@ package config
 type ConfigModel struct {
 	HTTP struct {
 		Addr string 
+
+		ValidateResponses bool
+		// Cache-Control max-age of order responses; 0 means revalidate every time
+		CacheMaxAge int
+		// most ids accepted by POST /orders/batch-get
+		BatchGetMax int
+	}
+
+	GRPC struct {
+		Addr string
 	}
 
 	Postgres struct {
@@ -13,6 +23,47 @@ type ConfigModel struct {
 		Brokers []string 
 		Topic   string
 		GroupID string
+		// messages saved per transaction; 1 saves them one by one
+		BatchSize int
+		// longest a partition's batch waits to fill up
+		BatchWaitMs int
+		// default workers of a subscription
+		Workers int
+		// how often lag per partition is measured
+		LagIntervalSeconds int
+		// lag above this for LagSustainSeconds fails the kafka_lag check; 0 never fails it
+		LagThreshold      int64
+		LagSustainSeconds int
+		// topics to consume; defaults to Topic with GroupID, JSON and Workers
+		Subscriptions []KafkaSubscription
+		// off, warn or strict: how JSON orders with unknown, missing or mistyped fields are treated
+		JSONStrict string
+		// messages that do not decode are copied here; empty only logs them
+		DLQTopic string
+		// encoding of the orders the producer emits: json, avro or protobuf
+		ProducerFormat string
+
+		TLS struct {
+			Enabled bool
+			// PEM files; without CAFile the system roots are trusted
+			CAFile   string
+			CertFile string
+			KeyFile  string
+			// accept any broker certificate; for development only
+			InsecureSkipVerify bool
+		}
+		SASL struct {
+			// PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512; empty disables SASL
+			Mechanism string
+			Username  string
+			Password  string
+		}
+	}
+
+	SchemaRegistry struct {
+		// http(s) URL of a Confluent-compatible registry or path of a JSON file
+		URL       string
+		TimeoutMs int
 	}
 
 	Redis struct {
@@ -22,4 +73,64 @@ type ConfigModel struct {
 		TTLSeconds int    
 		KeyPrefix  string 
 	}
+
+	Auth struct {
+		Enabled bool
+		// API key -> roles
+		APIKeys map[string][]string
+
+		JWTSecret     string
+		JWKSFile      string
+		JWTIssuer     string
+		JWTAudience   string
+		JWTRolesClaim string
+	}
+
+	PII struct {
+		// JSON key file for column encryption; empty stores plaintext
+		KeyFile string
+	}
+
+	Masking struct {
+		// role -> "field:mode,..." with modes none, partial, redact
+		Rules map[string]string
+		// role applied when the caller is not authenticated
+		DefaultRole string
+	}
+
+	RateLimit struct {
+		Enabled bool
+		// "METHOD /route/{param}" or "default" -> "count/unit[:burst]" or "off"
+		Rules     map[string]string
+		KeyPrefix string
+		// take the client address from X-Forwarded-For
+		TrustProxy bool
+	}
+
+	Health struct {
+		// checks whose failure makes /readyz answer 503; others only degrade
+		Critical  []string
+		TimeoutMs int
+	}
+
+	Stream struct {
+		BufferSize       int
+		HeartbeatSeconds int
+	}
+
+	WebSocket struct {
+		MaxConnections      int
+		WriteTimeoutSeconds int
+		PingSeconds         int
+	}
+}
+
+// KafkaSubscription is one consumed topic with the decoder that maps its
+// messages to orders.
+type KafkaSubscription struct {
+	Topic   string
+	Group   string
+	Decoder string
+	// saves running at once across the topic's partitions; 0 means one per partition
+	Workers int
 }
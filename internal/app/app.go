@@ -3,8 +3,14 @@ package app
 import (
 	"context"
 	"order-service/config"
+	"order-service/internal/domain/auth"
+	"order-service/internal/domain/delivery/graphql"
+	"order-service/internal/domain/delivery/grpc"
 	"order-service/internal/domain/delivery/http"
 	"order-service/internal/domain/delivery/kafka"
+	"order-service/internal/domain/health"
+	"order-service/internal/domain/masking"
+	"order-service/internal/domain/ratelimit"
 	"order-service/internal/domain/repository"
 	"order-service/internal/domain/usecase"
 
@@ -22,7 +28,13 @@ func New() *fx.App {
 		),
 		repository.Module(), 
 		usecase.Module(),
+		auth.Module(),
+		masking.Module(),
+		ratelimit.Module(),
+		health.Module(),
+		graphql.Module(),
 		http.Module(),
+		grpc.Module(),
 		kafka.Module(), 
 		fx.WithLogger(func(l *zap.Logger) fxevent.Logger { return &fxevent.ZapLogger{Logger: l} }),
 	)
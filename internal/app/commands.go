@@ -0,0 +1,251 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"order-service/config"
+	"order-service/internal/domain/delivery/kafka"
+	"order-service/internal/domain/entities"
+	"order-service/internal/domain/export"
+	"order-service/internal/domain/importer"
+	"order-service/internal/domain/repository"
+	"order-service/internal/domain/repository/postgres"
+	"order-service/internal/domain/usecase"
+
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// command is a one-off task run instead of the service, e.g.
+// `order-service rotate-keys -batch 1000`. parse reads the flags and
+// returns what to invoke once the dependencies are built.
+type command struct {
+	summary string
+	parse   func(fs *flag.FlagSet, args []string) (fx.Option, error)
+}
+
+var commands = map[string]command{
+	"import": {
+		summary: "load historical orders from an NDJSON file (gzip ok), resumable",
+		parse: func(fs *flag.FlagSet, args []string) (fx.Option, error) {
+			var opt importer.Options
+			fs.StringVar(&opt.Path, "file", "", "NDJSON file of orders, optionally gzip compressed")
+			fs.IntVar(&opt.Batch, "batch", 1000, "orders per transaction")
+			fs.StringVar(&opt.ErrorsPath, "errors", "", "file rejected lines are appended to (default <file>.errors.ndjson)")
+			if err := fs.Parse(args); err != nil {
+				return nil, err
+			}
+			if opt.Path == "" {
+				return nil, errors.New("import: -file is required")
+			}
+			if opt.ErrorsPath == "" {
+				opt.ErrorsPath = opt.Path + ".errors.ndjson"
+			}
+			return fx.Invoke(func(ctx context.Context, r *postgres.Repository, uc *usecase.OrderUC, l *zap.Logger) error {
+				defer r.Close()
+				st, err := importer.New(r, uc, l).Run(ctx, opt)
+				l.Sugar().Infow("import finished", "resumed_after_line", st.Resumed, "imported", st.Imported,
+					"rejected", st.Rejected, "errors_file", opt.ErrorsPath, "error", err)
+				return err
+			}), nil
+		},
+	},
+
+	"offsets-reset": {
+		summary: "move the consumer group's offsets to earliest, latest, a time or given offsets",
+		parse: func(fs *flag.FlagSet, args []string) (fx.Option, error) {
+			to := fs.String("to", "", "earliest, latest, an RFC 3339 time or partition=offset,...")
+			group := fs.String("group", "", "consumer group (default the topic's group in KAFKA_SUBSCRIPTIONS)")
+			topic := fs.String("topic", "", "topic (default KAFKA_TOPIC)")
+			if err := fs.Parse(args); err != nil {
+				return nil, err
+			}
+			pos, err := kafka.ParsePosition(*to)
+			if err != nil {
+				return nil, fmt.Errorf("offsets-reset: -to: %w", err)
+			}
+			return fx.Invoke(func(ctx context.Context, cfg *config.ConfigModel, l *zap.Logger) error {
+				g, t := *group, *topic
+				if t == "" {
+					t = cfg.Kafka.Topic
+				}
+				if g == "" {
+					g = cfg.Kafka.GroupID
+					for _, sub := range cfg.Kafka.Subscriptions {
+						if sub.Topic == t {
+							g = sub.Group
+						}
+					}
+				}
+				offsets, err := kafka.ResetOffsets(ctx, cfg, g, t, pos)
+				l.Sugar().Infow("offsets reset", "group", g, "topic", t, "to", pos, "offsets", offsets, "error", err)
+				return err
+			}), nil
+		},
+	},
+
+	"replay": {
+		summary: "re-ingest a range of the topic without touching the consumer group",
+		parse: func(fs *flag.FlagSet, args []string) (fx.Option, error) {
+			from := fs.String("from", "earliest", "earliest, latest, an RFC 3339 time or partition=offset,...")
+			to := fs.String("to", "latest", "end of the range, exclusive; same forms as -from")
+			topic := fs.String("topic", "", "topic (default KAFKA_TOPIC)")
+			batch := fs.Int("batch", 500, "orders per transaction")
+			if err := fs.Parse(args); err != nil {
+				return nil, err
+			}
+			start, err := kafka.ParsePosition(*from)
+			if err != nil {
+				return nil, fmt.Errorf("replay: -from: %w", err)
+			}
+			end, err := kafka.ParsePosition(*to)
+			if err != nil {
+				return nil, fmt.Errorf("replay: -to: %w", err)
+			}
+			return fx.Invoke(func(ctx context.Context, cfg *config.ConfigModel, uc *usecase.OrderUC, r *postgres.Repository, l *zap.Logger) error {
+				defer r.Close()
+				t := *topic
+				if t == "" {
+					t = cfg.Kafka.Topic
+				}
+				c, err := kafka.NewConsumer(cfg, uc, l)
+				if err != nil {
+					return err
+				}
+				st, err := c.Replay(ctx, t, start, end, *batch)
+				l.Sugar().Infow("replay finished", "topic", t, "from", start, "to", end, "messages", st.Messages,
+					"saved", st.Saved, "skipped", st.Skipped, "error", err)
+				return err
+			}), nil
+		},
+	},
+
+	"export": {
+		summary: "write orders of a date range as CSV or NDJSON, unmasked",
+		parse: func(fs *flag.FlagSet, args []string) (fx.Option, error) {
+			format := fs.String("format", export.FormatNDJSON, "csv (one row per item) or ndjson (one order per line)")
+			from := fs.String("from", "", "inclusive start, RFC 3339 or YYYY-MM-DD")
+			to := fs.String("to", "", "exclusive end, RFC 3339 or YYYY-MM-DD")
+			customer := fs.String("customer", "", "only this customer_id")
+			service := fs.String("delivery-service", "", "only this delivery_service")
+			out := fs.String("out", "-", "output file, - for stdout")
+			if err := fs.Parse(args); err != nil {
+				return nil, err
+			}
+			f := entities.OrderFilter{CustomerId: *customer, DeliveryService: *service}
+			var err error
+			if f.From, err = export.ParseTime(*from); err != nil {
+				return nil, err
+			}
+			if f.To, err = export.ParseTime(*to); err != nil {
+				return nil, err
+			}
+			return fx.Invoke(func(ctx context.Context, r *postgres.Repository, l *zap.Logger) error {
+				defer r.Close()
+				return exportOrders(ctx, r, f, *format, *out, l.Sugar())
+			}), nil
+		},
+	},
+
+	"rotate-keys": {
+		summary: "re-encrypt delivery PII with the active key from PII_KEY_FILE",
+		parse: func(fs *flag.FlagSet, args []string) (fx.Option, error) {
+			batch := fs.Int("batch", 500, "rows per transaction")
+			if err := fs.Parse(args); err != nil {
+				return nil, err
+			}
+			return fx.Invoke(func(ctx context.Context, r *postgres.Repository, l *zap.Logger) error {
+				defer r.Close()
+				n, err := r.RotateKeys(ctx, *batch)
+				l.Sugar().Infow("key rotation finished", "rows", n, "error", err)
+				return err
+			}), nil
+		},
+	},
+}
+
+// Command runs the command named by args[0] to completion.
+func Command(args []string) error {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n\n%s", args[0], usage())
+	}
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	invoke, err := cmd.parse(fs, args[1:])
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	app := fx.New(
+		fx.Provide(
+			func() context.Context { return ctx },
+			config.NewConfig,
+			zap.NewDevelopment,
+		),
+		repository.Module(),
+		usecase.Module(),
+		invoke,
+		fx.NopLogger,
+	)
+	return app.Err()
+}
+
+func exportOrders(ctx context.Context, r *postgres.Repository, f entities.OrderFilter, format, path string, log *zap.SugaredLogger) error {
+	var dst io.Writer = os.Stdout
+	if path != "-" {
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		dst = file
+	}
+	buf := bufio.NewWriterSize(dst, 1<<16)
+	w, err := export.NewWriter(format, buf)
+	if err != nil {
+		return err
+	}
+
+	n := 0
+	if err := r.Export(ctx, f, func(o *entities.Order) error {
+		n++
+		return w.Write(o)
+	}); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	log.Infow("export written", "orders", n, "format", format, "out", path)
+	return nil
+}
+
+func usage() string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString("commands:\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %-14s %s\n", name, commands[name].summary)
+	}
+	return b.String()
+}
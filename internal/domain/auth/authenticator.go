@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+
+	"order-service/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+type apiKey struct {
+	hash  [sha256.Size]byte
+	roles []string
+}
+
+type Authenticator struct {
+	enabled    bool
+	keys       []apiKey
+	secret     []byte
+	jwks       map[string]any
+	parser     *jwt.Parser
+	rolesClaim string
+	log        *zap.SugaredLogger
+}
+
+func NewAuthenticator(cfg *config.ConfigModel, l *zap.Logger) (*Authenticator, error) {
+	a := &Authenticator{
+		enabled:    cfg.Auth.Enabled,
+		rolesClaim: cfg.Auth.JWTRolesClaim,
+		log:        l.Named("auth").Sugar(),
+	}
+	if !a.enabled {
+		a.log.Warnw("authentication disabled, every caller gets full access")
+		return a, nil
+	}
+
+	for k, roles := range cfg.Auth.APIKeys {
+		a.keys = append(a.keys, apiKey{hash: sha256.Sum256([]byte(k)), roles: roles})
+	}
+
+	var methods []string
+	if cfg.Auth.JWTSecret != "" {
+		a.secret = []byte(cfg.Auth.JWTSecret)
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if cfg.Auth.JWKSFile != "" {
+		keys, err := loadJWKS(cfg.Auth.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %w", err)
+		}
+		a.jwks = keys
+		methods = append(methods, "RS256", "RS384", "RS512", "PS256", "PS384", "PS512",
+			"ES256", "ES384", "ES512", "EdDSA")
+	}
+
+	if len(methods) > 0 {
+		opts := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithExpirationRequired()}
+		if cfg.Auth.JWTIssuer != "" {
+			opts = append(opts, jwt.WithIssuer(cfg.Auth.JWTIssuer))
+		}
+		if cfg.Auth.JWTAudience != "" {
+			opts = append(opts, jwt.WithAudience(cfg.Auth.JWTAudience))
+		}
+		a.parser = jwt.NewParser(opts...)
+	}
+
+	if len(a.keys) == 0 && len(methods) == 0 {
+		return nil, errors.New("auth: enabled but neither API keys nor JWT keys are configured")
+	}
+	a.log.Infow("authentication enabled", "api_keys", len(a.keys), "jwt_hmac", a.secret != nil, "jwks_keys", len(a.jwks))
+	return a, nil
+}
+
+func (a *Authenticator) Enabled() bool { return a.enabled }
+
+// APIKey resolves a static key. Every configured key is compared so the
+// time taken does not depend on which one matched.
+func (a *Authenticator) APIKey(key string) (*Principal, error) {
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	h := sha256.Sum256([]byte(key))
+	var found *apiKey
+	for i := range a.keys {
+		if subtle.ConstantTimeCompare(h[:], a.keys[i].hash[:]) == 1 {
+			found = &a.keys[i]
+		}
+	}
+	if found == nil {
+		return nil, ErrInvalidCredentials
+	}
+	return &Principal{
+		Subject: fmt.Sprintf("key:%x", h[:4]),
+		Method:  "api_key",
+		Roles:   found.roles,
+	}, nil
+}
+
+// Token verifies a JWT and reads the roles claim, which may be an array or
+// a space separated string.
+func (a *Authenticator) Token(raw string) (*Principal, error) {
+	if raw == "" {
+		return nil, ErrNoCredentials
+	}
+	if a.parser == nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := a.parser.ParseWithClaims(raw, claims, a.keyFor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	sub, _ := claims.GetSubject()
+	p := &Principal{Subject: sub, Method: "jwt"}
+	switch v := claims[a.rolesClaim].(type) {
+	case []any:
+		for _, r := range v {
+			if s, ok := r.(string); ok {
+				p.Roles = append(p.Roles, s)
+			}
+		}
+	case string:
+		p.Roles = strings.Fields(v)
+	}
+	return p, nil
+}
+
+func (a *Authenticator) keyFor(t *jwt.Token) (any, error) {
+	if strings.HasPrefix(t.Method.Alg(), "HS") {
+		if a.secret == nil {
+			return nil, errors.New("hmac tokens not accepted")
+		}
+		return a.secret, nil
+	}
+	kid, _ := t.Header["kid"].(string)
+	if key, ok := a.jwks[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(a.jwks) == 1 {
+		for _, key := range a.jwks {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown kid %q", kid)
+}
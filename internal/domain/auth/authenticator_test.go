@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"order-service/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	testSecret   = "test-secret"
+	testIssuer   = "https://issuer.test"
+	testAudience = "order-service"
+)
+
+type testKeys struct {
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	rk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKeys{rsa: rk, ec: ek}
+}
+
+// writeJWKS publishes the public halves of k as kids "rsa-1" and "ec-1".
+func writeJWKS(t *testing.T, k testKeys) string {
+	t.Helper()
+	b64 := func(n *big.Int) string { return base64.RawURLEncoding.EncodeToString(n.Bytes()) }
+	set := map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": b64(k.rsa.N), "e": b64(big.NewInt(int64(k.rsa.E)))},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(k.ec.X), "y": b64(k.ec.Y)},
+	}}
+	b, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestAuthenticator(t *testing.T, jwks string) *Authenticator {
+	t.Helper()
+	cfg := &config.ConfigModel{}
+	cfg.Auth.Enabled = true
+	cfg.Auth.APIKeys = map[string][]string{"reader-key": {RoleReader}, "admin-key": {RoleAdmin, RoleIngest}}
+	cfg.Auth.JWTSecret = testSecret
+	cfg.Auth.JWKSFile = jwks
+	cfg.Auth.JWTIssuer = testIssuer
+	cfg.Auth.JWTAudience = testAudience
+	cfg.Auth.JWTRolesClaim = "roles"
+	a, err := NewAuthenticator(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// claims are valid for testIssuer and testAudience unless changed by edit.
+func claims(edit func(jwt.MapClaims)) jwt.MapClaims {
+	c := jwt.MapClaims{
+		"sub":   "user-1",
+		"iss":   testIssuer,
+		"aud":   testAudience,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": []string{RoleReader, RoleSupport},
+	}
+	if edit != nil {
+		edit(c)
+	}
+	return c
+}
+
+func mint(t *testing.T, m jwt.SigningMethod, kid string, key any, c jwt.MapClaims) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(m, c)
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	s, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestToken(t *testing.T) {
+	keys := newTestKeys(t)
+	a := newTestAuthenticator(t, writeJWKS(t, keys))
+	other := newTestKeys(t)
+
+	tests := []struct {
+		name  string
+		token string
+		roles []string
+		err   error
+	}{
+		{"hs256", mint(t, jwt.SigningMethodHS256, "", []byte(testSecret), claims(nil)),
+			[]string{RoleReader, RoleSupport}, nil},
+		{"rs256", mint(t, jwt.SigningMethodRS256, "rsa-1", keys.rsa, claims(nil)),
+			[]string{RoleReader, RoleSupport}, nil},
+		{"es256", mint(t, jwt.SigningMethodES256, "ec-1", keys.ec, claims(nil)),
+			[]string{RoleReader, RoleSupport}, nil},
+		{"roles as space separated string", mint(t, jwt.SigningMethodHS256, "", []byte(testSecret),
+			claims(func(c jwt.MapClaims) { c["roles"] = "admin  ingest" })),
+			[]string{RoleAdmin, RoleIngest}, nil},
+		{"non-string roles are ignored", mint(t, jwt.SigningMethodHS256, "", []byte(testSecret),
+			claims(func(c jwt.MapClaims) { c["roles"] = []any{"admin", 7, true} })),
+			[]string{RoleAdmin}, nil},
+		{"no roles claim", mint(t, jwt.SigningMethodHS256, "", []byte(testSecret),
+			claims(func(c jwt.MapClaims) { delete(c, "roles") })),
+			nil, nil},
+
+		{"empty", "", nil, ErrNoCredentials},
+		{"garbage", "not.a.jwt", nil, ErrInvalidCredentials},
+		{"hs256 bad signature", mint(t, jwt.SigningMethodHS256, "", []byte("other-secret"), claims(nil)),
+			nil, ErrInvalidCredentials},
+		{"rs256 bad signature", mint(t, jwt.SigningMethodRS256, "rsa-1", other.rsa, claims(nil)),
+			nil, ErrInvalidCredentials},
+		{"es256 bad signature", mint(t, jwt.SigningMethodES256, "ec-1", other.ec, claims(nil)),
+			nil, ErrInvalidCredentials},
+		{"rs256 key under the ec kid", mint(t, jwt.SigningMethodRS256, "ec-1", keys.rsa, claims(nil)),
+			nil, ErrInvalidCredentials},
+		{"unknown kid", mint(t, jwt.SigningMethodRS256, "rsa-2", keys.rsa, claims(nil)),
+			nil, ErrInvalidCredentials},
+		{"no kid with several keys", mint(t, jwt.SigningMethodRS256, "", keys.rsa, claims(nil)),
+			nil, ErrInvalidCredentials},
+		{"expired", mint(t, jwt.SigningMethodHS256, "", []byte(testSecret),
+			claims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() })),
+			nil, ErrInvalidCredentials},
+		{"no expiry", mint(t, jwt.SigningMethodHS256, "", []byte(testSecret),
+			claims(func(c jwt.MapClaims) { delete(c, "exp") })),
+			nil, ErrInvalidCredentials},
+		{"not yet valid", mint(t, jwt.SigningMethodHS256, "", []byte(testSecret),
+			claims(func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Hour).Unix() })),
+			nil, ErrInvalidCredentials},
+		{"wrong issuer", mint(t, jwt.SigningMethodHS256, "", []byte(testSecret),
+			claims(func(c jwt.MapClaims) { c["iss"] = "https://evil.test" })),
+			nil, ErrInvalidCredentials},
+		{"wrong audience", mint(t, jwt.SigningMethodHS256, "", []byte(testSecret),
+			claims(func(c jwt.MapClaims) { c["aud"] = "other-service" })),
+			nil, ErrInvalidCredentials},
+		{"audience among several", mint(t, jwt.SigningMethodHS256, "", []byte(testSecret),
+			claims(func(c jwt.MapClaims) { c["aud"] = []string{"other-service", testAudience} })),
+			[]string{RoleReader, RoleSupport}, nil},
+		{"alg none", mint(t, jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType, claims(nil)),
+			nil, ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := a.Token(tt.token)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Subject != "user-1" || p.Method != "jwt" {
+				t.Errorf("principal = %+v", p)
+			}
+			if !reflect.DeepEqual(p.Roles, tt.roles) {
+				t.Errorf("roles = %v, want %v", p.Roles, tt.roles)
+			}
+		})
+	}
+}
+
+func TestTokenRolesClaim(t *testing.T) {
+	cfg := &config.ConfigModel{}
+	cfg.Auth.Enabled = true
+	cfg.Auth.JWTSecret = testSecret
+	cfg.Auth.JWTRolesClaim = "scope"
+	a, err := NewAuthenticator(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok := mint(t, jwt.SigningMethodHS256, "", []byte(testSecret), claims(func(c jwt.MapClaims) { c["scope"] = "admin" }))
+	p, err := a.Token(tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p.Roles, []string{RoleAdmin}) {
+		t.Errorf("roles = %v, want the scope claim only", p.Roles)
+	}
+}
+
+func TestTokenOnlyJWKS(t *testing.T) {
+	keys := newTestKeys(t)
+	cfg := &config.ConfigModel{}
+	cfg.Auth.Enabled = true
+	cfg.Auth.JWKSFile = writeJWKS(t, keys)
+	cfg.Auth.JWTRolesClaim = "roles"
+	a, err := NewAuthenticator(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Token(mint(t, jwt.SigningMethodES256, "ec-1", keys.ec, claims(nil))); err != nil {
+		t.Fatal(err)
+	}
+	// without a secret HMAC tokens are refused, whatever key signed them
+	hs := mint(t, jwt.SigningMethodHS256, "", []byte(testSecret), claims(nil))
+	if _, err := a.Token(hs); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("hs256 err = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	a := newTestAuthenticator(t, writeJWKS(t, newTestKeys(t)))
+
+	p, err := a.APIKey("admin-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Method != "api_key" || !reflect.DeepEqual(p.Roles, []string{RoleAdmin, RoleIngest}) {
+		t.Errorf("principal = %+v", p)
+	}
+	if p.Subject == "" || p.Subject == "admin-key" {
+		t.Errorf("subject %q must identify the key without revealing it", p.Subject)
+	}
+
+	if _, err := a.APIKey(""); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("empty key err = %v, want ErrNoCredentials", err)
+	}
+	for _, k := range []string{"unknown", "reader-key ", "READER-KEY"} {
+		if _, err := a.APIKey(k); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("key %q err = %v, want ErrInvalidCredentials", k, err)
+		}
+	}
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	cfg := &config.ConfigModel{}
+	cfg.Auth.Enabled = true
+	if _, err := NewAuthenticator(cfg, zap.NewNop()); err == nil {
+		t.Error("enabled without keys must fail")
+	}
+
+	cfg.Auth.JWKSFile = filepath.Join(t.TempDir(), "missing.json")
+	if _, err := NewAuthenticator(cfg, zap.NewNop()); err == nil {
+		t.Error("missing JWKS file must fail")
+	}
+
+	cfg = &config.ConfigModel{}
+	a, err := NewAuthenticator(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Enabled() {
+		t.Error("disabled authenticator reports enabled")
+	}
+}
@@ -0,0 +1,43 @@
+package auth
+
+import "context"
+
+const (
+	RoleReader  = "reader"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+	RoleIngest  = "ingest"
+)
+
+type Principal struct {
+	Subject string
+	// "api_key" or "jwt"
+	Method string
+	Roles  []string
+}
+
+func (p *Principal) HasAny(roles ...string) bool {
+	if p == nil {
+		return false
+	}
+	for _, have := range p.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the authenticated caller or nil.
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
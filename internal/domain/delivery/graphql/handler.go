@@ -0,0 +1,35 @@
+package graphql
+
+import (
+	_ "embed"
+	"net/http"
+
+	"order-service/internal/domain/masking"
+	"order-service/internal/domain/usecase"
+
+	gql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+	"go.uber.org/zap"
+)
+
+//go:embed schema.graphql
+var schemaSDL string
+
+type Handler struct {
+	h http.Handler
+}
+
+func NewHandler(uc *usecase.OrderUC, mask *masking.Policy, l *zap.Logger) (*Handler, error) {
+	schema, err := gql.ParseSchema(schemaSDL, &rootResolver{uc: uc, mask: mask, log: l.Named("graphql").Sugar()},
+		gql.MaxDepth(6),
+		gql.MaxParallelism(10),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{h: &relay.Handler{Schema: schema}}, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.h.ServeHTTP(w, r)
+}
@@ -0,0 +1,9 @@
+package graphql
+
+import "go.uber.org/fx"
+
+func Module() fx.Option {
+	return fx.Module("graphql",
+		fx.Provide(NewHandler),
+	)
+}
@@ -0,0 +1,329 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+
+	"order-service/internal/domain/entities"
+	"order-service/internal/domain/logctx"
+	"order-service/internal/domain/masking"
+	"order-service/internal/domain/usecase"
+
+	"github.com/google/uuid"
+	gql "github.com/graph-gophers/graphql-go"
+	"go.uber.org/zap"
+)
+
+// Long carries 64-bit integers; the built-in Int is 32-bit.
+type Long int64
+
+func (Long) ImplementsGraphQLType(name string) bool { return name == "Long" }
+
+func (l *Long) UnmarshalGraphQL(input any) error {
+	switch v := input.(type) {
+	case int32:
+		*l = Long(v)
+	case int64:
+		*l = Long(v)
+	case float64:
+		if v != math.Trunc(v) {
+			return fmt.Errorf("Long: %v is not an integer", v)
+		}
+		*l = Long(v)
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("Long: %w", err)
+		}
+		*l = Long(n)
+	default:
+		return fmt.Errorf("Long: unexpected type %T", input)
+	}
+	return nil
+}
+
+func (l Long) MarshalJSON() ([]byte, error) { return json.Marshal(int64(l)) }
+
+type rootResolver struct {
+	uc   *usecase.OrderUC
+	mask *masking.Policy
+	log  *zap.SugaredLogger
+}
+
+var (
+	errUnavailable = errors.New("storage unavailable")
+	errInternal    = errors.New("internal error")
+)
+
+// publicErr maps a use case error to what the client sees in the errors
+// array, as the problem responses and gRPC statuses do. Storage errors
+// carry driver details, so those are only logged.
+func publicErr(ctx context.Context, log *zap.SugaredLogger, err error) error {
+	switch {
+	case errors.Is(err, entities.ErrNotFound), errors.Is(err, entities.ErrInvalidID),
+		errors.Is(err, entities.ErrValidation), errors.Is(err, context.Canceled):
+		return err
+	case errors.Is(err, entities.ErrUnavailable):
+		logctx.From(ctx, log).Errorw("storage unavailable", "error", err)
+		return errUnavailable
+	default:
+		logctx.From(ctx, log).Errorw("internal error", "error", err)
+		return errInternal
+	}
+}
+
+func (r *rootResolver) Order(ctx context.Context, args struct{ OrderUid gql.ID }) (*orderResolver, error) {
+	o, err := r.uc.Get(ctx, string(args.OrderUid))
+	if errors.Is(err, entities.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, publicErr(ctx, r.log, err)
+	}
+	return &orderResolver{o: r.mask.Apply(ctx, o)}, nil
+}
+
+type orderFilterInput struct {
+	CustomerId      *string
+	DeliveryService *string
+	TrackNumber     *string
+	Email           *string
+	Phone           *string
+	MinAmount       *Long
+	CreatedFrom     *gql.Time
+	CreatedTo       *gql.Time
+}
+
+type ordersArgs struct {
+	Filter *orderFilterInput
+	First  *int32
+	After  *string
+}
+
+func (r *rootResolver) Orders(ctx context.Context, args ordersArgs) (*connectionResolver, error) {
+	var f entities.OrderFilter
+	if in := args.Filter; in != nil {
+		f.CustomerId = deref(in.CustomerId)
+		f.DeliveryService = deref(in.DeliveryService)
+		f.TrackNumber = deref(in.TrackNumber)
+		f.Email = deref(in.Email)
+		f.Phone = deref(in.Phone)
+		if in.MinAmount != nil {
+			f.MinAmount = int64(*in.MinAmount)
+		}
+		if in.CreatedFrom != nil {
+			f.From = in.CreatedFrom.Time
+		}
+		if in.CreatedTo != nil {
+			f.To = in.CreatedTo.Time
+		}
+	}
+	if err := r.mask.CheckFilter(ctx, f); err != nil {
+		return nil, err
+	}
+	if args.First != nil {
+		f.Limit = int(*args.First)
+	}
+	if args.After != nil && *args.After != "" {
+		c, err := entities.DecodePageCursor(*args.After)
+		if err != nil {
+			return nil, err
+		}
+		f.After = c
+	}
+
+	list, next, err := r.uc.ListHeaders(ctx, f)
+	if err != nil {
+		return nil, publicErr(ctx, r.log, err)
+	}
+
+	b := &batch{uc: r.uc, mask: r.mask, log: r.log, ids: make([]uuid.UUID, 0, len(list))}
+	nodes := make([]*orderResolver, 0, len(list))
+	for _, o := range list {
+		b.ids = append(b.ids, o.OrderId)
+		nodes = append(nodes, &orderResolver{o: o, batch: b})
+	}
+	res := &connectionResolver{nodes: nodes}
+	if next != nil {
+		s := next.Encode()
+		res.next = &s
+	}
+	return res, nil
+}
+
+type connectionResolver struct {
+	nodes []*orderResolver
+	next  *string
+}
+
+func (c *connectionResolver) Nodes() []*orderResolver { return c.nodes }
+func (c *connectionResolver) NextCursor() *string     { return c.next }
+
+// batch loads nested objects of a whole page at once the first time any
+// order of the page asks for them.
+type batch struct {
+	uc   *usecase.OrderUC
+	mask *masking.Policy
+	log  *zap.SugaredLogger
+	ids  []uuid.UUID
+
+	delOnce sync.Once
+	dels    map[uuid.UUID]*entities.Delivery
+	delErr  error
+
+	payOnce sync.Once
+	pays    map[uuid.UUID]*entities.Payment
+	payErr  error
+
+	itemOnce sync.Once
+	items    map[uuid.UUID][]entities.Item
+	itemErr  error
+}
+
+func (b *batch) delivery(ctx context.Context, id uuid.UUID) (*entities.Delivery, error) {
+	b.delOnce.Do(func() {
+		if b.dels, b.delErr = b.uc.Deliveries(ctx, b.ids); b.delErr != nil {
+			b.delErr = publicErr(ctx, b.log, b.delErr)
+		}
+	})
+	if b.delErr != nil {
+		return nil, b.delErr
+	}
+	var d entities.Delivery
+	if v, ok := b.dels[id]; ok {
+		d = b.mask.Delivery(ctx, *v)
+	}
+	return &d, nil
+}
+
+func (b *batch) payment(ctx context.Context, id uuid.UUID) (*entities.Payment, error) {
+	b.payOnce.Do(func() {
+		if b.pays, b.payErr = b.uc.Payments(ctx, b.ids); b.payErr != nil {
+			b.payErr = publicErr(ctx, b.log, b.payErr)
+		}
+	})
+	if b.payErr != nil {
+		return nil, b.payErr
+	}
+	var p entities.Payment
+	if v, ok := b.pays[id]; ok {
+		p = b.mask.Payment(ctx, *v)
+	}
+	return &p, nil
+}
+
+func (b *batch) itemsOf(ctx context.Context, id uuid.UUID) ([]entities.Item, error) {
+	b.itemOnce.Do(func() {
+		if b.items, b.itemErr = b.uc.Items(ctx, b.ids); b.itemErr != nil {
+			b.itemErr = publicErr(ctx, b.log, b.itemErr)
+		}
+	})
+	if b.itemErr != nil {
+		return nil, b.itemErr
+	}
+	return b.items[id], nil
+}
+
+// orderResolver wraps either a fully loaded order (batch == nil) or an
+// order header whose nested objects come from batch.
+type orderResolver struct {
+	o     *entities.Order
+	batch *batch
+}
+
+func (r *orderResolver) OrderUid() gql.ID          { return gql.ID(r.o.OrderId.String()) }
+func (r *orderResolver) TrackNumber() string       { return r.o.TrackNumber }
+func (r *orderResolver) Entry() string             { return r.o.Entry }
+func (r *orderResolver) Locale() string            { return r.o.Locale }
+func (r *orderResolver) InternalSignature() string { return r.o.InternalSignature }
+func (r *orderResolver) CustomerId() string        { return r.o.CustomerId }
+func (r *orderResolver) DeliveryService() string   { return r.o.DeliveryService }
+func (r *orderResolver) Shardkey() Long            { return Long(r.o.ShardKey) }
+func (r *orderResolver) SmId() int32               { return int32(r.o.SmId) }
+func (r *orderResolver) DateCreated() gql.Time     { return gql.Time{Time: r.o.DateCreated} }
+
+func (r *orderResolver) Delivery(ctx context.Context) (*deliveryResolver, error) {
+	if r.batch == nil {
+		return &deliveryResolver{&r.o.Delivery}, nil
+	}
+	d, err := r.batch.delivery(ctx, r.o.OrderId)
+	if err != nil {
+		return nil, err
+	}
+	return &deliveryResolver{d}, nil
+}
+
+func (r *orderResolver) Payment(ctx context.Context) (*paymentResolver, error) {
+	if r.batch == nil {
+		return &paymentResolver{&r.o.Payment}, nil
+	}
+	p, err := r.batch.payment(ctx, r.o.OrderId)
+	if err != nil {
+		return nil, err
+	}
+	return &paymentResolver{p}, nil
+}
+
+func (r *orderResolver) Items(ctx context.Context) ([]*itemResolver, error) {
+	list := r.o.Items
+	if r.batch != nil {
+		var err error
+		if list, err = r.batch.itemsOf(ctx, r.o.OrderId); err != nil {
+			return nil, err
+		}
+	}
+	out := make([]*itemResolver, 0, len(list))
+	for i := range list {
+		out = append(out, &itemResolver{&list[i]})
+	}
+	return out, nil
+}
+
+type deliveryResolver struct{ d *entities.Delivery }
+
+func (r *deliveryResolver) Name() string    { return r.d.Name }
+func (r *deliveryResolver) Phone() string   { return r.d.Phone }
+func (r *deliveryResolver) Zip() string     { return r.d.Zip }
+func (r *deliveryResolver) City() string    { return r.d.City }
+func (r *deliveryResolver) Address() string { return r.d.Address }
+func (r *deliveryResolver) Region() string  { return r.d.Region }
+func (r *deliveryResolver) Email() string   { return r.d.Email }
+
+type paymentResolver struct{ p *entities.Payment }
+
+func (r *paymentResolver) TransactionId() string { return r.p.TransactionId }
+func (r *paymentResolver) RequestId() string     { return r.p.RequestId }
+func (r *paymentResolver) Currency() string      { return r.p.Currency }
+func (r *paymentResolver) Provider() string      { return r.p.Provider }
+func (r *paymentResolver) Amount() Long          { return Long(r.p.Amount) }
+func (r *paymentResolver) PaymentDt() Long       { return Long(r.p.PaymentDt) }
+func (r *paymentResolver) Bank() string          { return r.p.Bank }
+func (r *paymentResolver) DeliveryCost() Long    { return Long(r.p.DeliveryCost) }
+func (r *paymentResolver) GoodsTotal() Long      { return Long(r.p.GoodsTotal) }
+func (r *paymentResolver) CustomFee() Long       { return Long(r.p.CustomFee) }
+
+type itemResolver struct{ it *entities.Item }
+
+func (r *itemResolver) ChrtId() Long        { return Long(r.it.ChrtId) }
+func (r *itemResolver) TrackNumber() string { return r.it.TrackNumber }
+func (r *itemResolver) Price() Long         { return Long(r.it.Price) }
+func (r *itemResolver) Rid() string         { return r.it.RID }
+func (r *itemResolver) Name() string        { return r.it.Name }
+func (r *itemResolver) Sale() int32         { return int32(r.it.Sale) }
+func (r *itemResolver) Size() string        { return r.it.Size }
+func (r *itemResolver) TotalPrice() Long    { return Long(r.it.TotalPrice) }
+func (r *itemResolver) NmId() Long          { return Long(r.it.NmID) }
+func (r *itemResolver) Brand() string       { return r.it.Brand }
+func (r *itemResolver) Status() int32       { return int32(r.it.Status) }
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,77 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	"order-service/internal/domain/auth"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var readRoles = []string{auth.RoleReader, auth.RoleSupport, auth.RoleAdmin}
+
+// authorize applies the HTTP rules to OrderService calls: the same API keys
+// and tokens, read roles only. Health and reflection stay open.
+func (s *Server) authorize(ctx context.Context, method string) (context.Context, error) {
+	if !s.auth.Enabled() || !strings.HasPrefix(method, "/order.v1.OrderService/") {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(k string) string {
+		if v := md.Get(k); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+
+	var (
+		p   *auth.Principal
+		err = auth.ErrNoCredentials
+	)
+	if key := first("x-api-key"); key != "" {
+		p, err = s.auth.APIKey(key)
+	} else if h := first("authorization"); h != "" {
+		scheme, token, _ := strings.Cut(h, " ")
+		if strings.EqualFold(scheme, "Bearer") {
+			p, err = s.auth.Token(strings.TrimSpace(token))
+		} else {
+			err = auth.ErrInvalidCredentials
+		}
+	}
+	if err != nil {
+		s.log.Warnw("authentication failed", "method", method, "error", err)
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if !p.HasAny(readRoles...) {
+		return nil, status.Errorf(codes.PermissionDenied, "requires one of roles: %s", strings.Join(readRoles, ", "))
+	}
+	return auth.WithPrincipal(ctx, p), nil
+}
+
+func (s *Server) unaryAuth(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := s.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) streamAuth(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authorize(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authStream{ServerStream: ss, ctx: ctx})
+}
+
+type authStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authStream) Context() context.Context { return s.ctx }
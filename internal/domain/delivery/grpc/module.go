@@ -0,0 +1,10 @@
+package grpc
+
+import "go.uber.org/fx"
+
+func Module() fx.Option {
+	return fx.Module("grpc",
+		fx.Provide(NewServer),
+		fx.Invoke(func(s *Server) error { return s.OnStart() }),
+	)
+}
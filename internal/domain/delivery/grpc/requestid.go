@@ -0,0 +1,36 @@
+package grpc
+
+import (
+	"context"
+
+	"order-service/internal/domain/logctx"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const requestIDKey = "x-request-id"
+
+// withRequestID does for gRPC what the HTTP middleware does: adopt the
+// caller's x-request-id or make one, and send it back as a header.
+func withRequestID(ctx context.Context) context.Context {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(requestIDKey); len(v) > 0 {
+			id = v[0]
+		}
+	}
+	if !logctx.Accept(id) {
+		id = logctx.NewID()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, id))
+	return logctx.WithRequestID(ctx, id)
+}
+
+func unaryRequestID(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(withRequestID(ctx), req)
+}
+
+func streamRequestID(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &authStream{ServerStream: ss, ctx: withRequestID(ss.Context())})
+}
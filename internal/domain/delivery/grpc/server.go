@@ -0,0 +1,193 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	orderv1 "order-service/api/order/v1"
+	"order-service/config"
+	"order-service/internal/domain/auth"
+	"order-service/internal/domain/entities"
+	"order-service/internal/domain/masking"
+	"order-service/internal/domain/usecase"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+type Server struct {
+	orderv1.UnimplementedOrderServiceServer
+
+	cfg  *config.ConfigModel
+	uc   *usecase.OrderUC
+	auth *auth.Authenticator
+	mask *masking.Policy
+	log  *zap.SugaredLogger
+}
+
+func NewServer(cfg *config.ConfigModel, uc *usecase.OrderUC, a *auth.Authenticator, mask *masking.Policy, l *zap.Logger) (*Server, error) {
+	return &Server{cfg: cfg, uc: uc, auth: a, mask: mask, log: l.Named("grpc").Sugar()}, nil
+}
+
+func (s *Server) OnStart() error {
+	lis, err := net.Listen("tcp", s.cfg.GRPC.Addr)
+	if err != nil {
+		return err
+	}
+
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryRequestID, s.unaryAuth),
+		grpc.ChainStreamInterceptor(streamRequestID, s.streamAuth),
+	)
+	orderv1.RegisterOrderServiceServer(srv, s)
+
+	hs := health.NewServer()
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	hs.SetServingStatus(orderv1.OrderService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+	reflection.Register(srv)
+
+	go func() {
+		s.log.Infow("grpc listen", "addr", s.cfg.GRPC.Addr)
+		if err := srv.Serve(lis); err != nil && err != grpc.ErrServerStopped {
+			s.log.Errorw("grpc serve error", "error", err)
+		}
+	}()
+	return nil
+}
+
+func (s *Server) GetOrder(ctx context.Context, req *orderv1.GetOrderRequest) (*orderv1.Order, error) {
+	o, err := s.uc.Get(ctx, req.GetOrderUid())
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return orderv1.FromEntity(s.mask.Apply(ctx, o)), nil
+}
+
+func (s *Server) ListOrders(ctx context.Context, req *orderv1.ListOrdersRequest) (*orderv1.ListOrdersResponse, error) {
+	f := entities.OrderFilter{Limit: int(req.GetPageSize())}
+	return s.list(ctx, f, req.GetPageToken())
+}
+
+func (s *Server) SearchOrders(ctx context.Context, req *orderv1.SearchOrdersRequest) (*orderv1.ListOrdersResponse, error) {
+	f := entities.OrderFilter{
+		CustomerId:      req.GetCustomerId(),
+		DeliveryService: req.GetDeliveryService(),
+		TrackNumber:     req.GetTrackNumber(),
+		Email:           req.GetEmail(),
+		Phone:           req.GetPhone(),
+		MinAmount:       req.GetMinAmount(),
+		Limit:           int(req.GetPageSize()),
+	}
+	if req.CreatedFrom != nil {
+		f.From = req.CreatedFrom.AsTime()
+	}
+	if req.CreatedTo != nil {
+		f.To = req.CreatedTo.AsTime()
+	}
+	if err := s.mask.CheckFilter(ctx, f); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	return s.list(ctx, f, req.GetPageToken())
+}
+
+func (s *Server) list(ctx context.Context, f entities.OrderFilter, token string) (*orderv1.ListOrdersResponse, error) {
+	if token != "" {
+		c, err := entities.DecodePageCursor(token)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		f.After = c
+	}
+
+	list, next, err := s.uc.List(ctx, f)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+
+	resp := &orderv1.ListOrdersResponse{Orders: make([]*orderv1.Order, 0, len(list))}
+	for _, o := range list {
+		resp.Orders = append(resp.Orders, orderv1.FromEntity(s.mask.Apply(ctx, o)))
+	}
+	if next != nil {
+		resp.NextPageToken = next.Encode()
+	}
+	return resp, nil
+}
+
+func (s *Server) WatchOrders(req *orderv1.WatchOrdersRequest, stream orderv1.OrderService_WatchOrdersServer) error {
+	var only uuid.UUID
+	if v := req.GetOrderUid(); v != "" {
+		u, err := uuid.Parse(v)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "bad order_uid: %v", err)
+		}
+		only = u
+	}
+
+	filter := func(o *entities.Order) bool {
+		if only != uuid.Nil && o.OrderId != only {
+			return false
+		}
+		if c := req.GetCustomerId(); c != "" && o.CustomerId != c {
+			return false
+		}
+		if d := req.GetDeliveryService(); d != "" && o.DeliveryService != d {
+			return false
+		}
+		return o.Payment.Amount >= req.GetMinAmount()
+	}
+
+	sub, backlog := s.uc.Subscribe(req.GetAfterEventId(), filter)
+	defer sub.Close()
+	s.log.Infow("watch open", "after_event_id", req.GetAfterEventId(), "backlog", len(backlog))
+
+	send := func(ev usecase.OrderEvent) error {
+		o := s.mask.Apply(stream.Context(), ev.Order)
+		return stream.Send(&orderv1.OrderEvent{EventId: ev.ID, Order: orderv1.FromEntity(o)})
+	}
+	for _, ev := range backlog {
+		if err := send(ev); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			s.log.Debugw("watch closed by client")
+			return nil
+		case ev, ok := <-sub.C:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "subscriber too slow, resume with after_event_id")
+			}
+			if err := send(ev); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) toStatus(err error) error {
+	switch {
+	case errors.Is(err, entities.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, entities.ErrInvalidID), errors.Is(err, entities.ErrValidation):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, entities.ErrUnavailable):
+		s.log.Errorw("storage unavailable", "error", err)
+		return status.Error(codes.Unavailable, "storage unavailable")
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	default:
+		s.log.Errorw("internal error", "error", err)
+		return status.Error(codes.Internal, "internal error")
+	}
+}
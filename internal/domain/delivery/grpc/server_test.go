@@ -0,0 +1,39 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	orderv1 "order-service/api/order/v1"
+	"order-service/config"
+	"order-service/internal/domain/auth"
+	"order-service/internal/domain/masking"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSearchOrdersHiddenFilter(t *testing.T) {
+	cfg := &config.ConfigModel{}
+	cfg.Masking.Rules = map[string]string{auth.RoleReader: "*:redact"}
+	mask, err := masking.NewPolicy(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewServer(cfg, nil, nil, mask, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "t", Roles: []string{auth.RoleReader}})
+
+	for _, req := range []*orderv1.SearchOrdersRequest{
+		{Email: "test@gmail.com"},
+		{Phone: "+9720000000"},
+	} {
+		_, err := s.SearchOrders(ctx, req)
+		if status.Code(err) != codes.PermissionDenied {
+			t.Errorf("%v: err = %v, want PermissionDenied", req, err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"order-service/internal/domain/logctx"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// requestID adopts the caller's X-Request-ID or makes one up, stores it in
+// the context for every logger downstream and echoes it back.
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !logctx.Accept(id) {
+			id = logctx.NewID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logctx.WithRequestID(r.Context(), id)))
+	})
+}
+
+// accessEntry is filled in by inner middleware that knows more about the
+// request than the access log sees from outside.
+type accessEntry struct {
+	subject string
+}
+
+type accessKey struct{}
+
+func accessEntryFrom(ctx context.Context) *accessEntry {
+	e, _ := ctx.Value(accessKey{}).(*accessEntry)
+	return e
+}
+
+// accessLog writes one line per request once it is done.
+func (s *Server) accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &accessEntry{}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), accessKey{}, entry)))
+
+		status := ww.Status()
+		if status == 0 {
+			// hijacked (websocket) or nothing written
+			status = http.StatusOK
+		}
+		route := ""
+		if rc := chi.RouteContext(r.Context()); rc != nil {
+			route = rc.RoutePattern()
+		}
+		log := logctx.From(r.Context(), s.log).With(
+			"method", r.Method,
+			"path", r.URL.Path,
+			"route", route,
+			"status", status,
+			"bytes", ww.BytesWritten(),
+			"latency_ms", time.Since(start).Milliseconds(),
+			"client", r.RemoteAddr,
+			"user_agent", r.UserAgent(),
+		)
+		if entry.subject != "" {
+			log = log.With("subject", entry.subject)
+		}
+		switch {
+		case status >= 500:
+			log.Errorw("access")
+		case status >= 400:
+			log.Warnw("access")
+		default:
+			log.Infow("access")
+		}
+	})
+}
+
+// logger tags lines with the id of request r.
+func (s *Server) logger(r *http.Request) *zap.SugaredLogger { return logctx.From(r.Context(), s.log) }
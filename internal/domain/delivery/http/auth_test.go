@@ -0,0 +1,140 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"order-service/internal/domain/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-secret"
+
+func mintToken(t *testing.T, secret string, roles ...string) string {
+	t.Helper()
+	tok, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   "user-1",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": roles,
+	}).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	cfg := testConfig()
+	cfg.Auth.Enabled = true
+	cfg.Auth.JWTSecret = testSecret
+	cfg.Auth.JWTRolesClaim = "roles"
+	cfg.Auth.APIKeys = map[string][]string{
+		"reader-key":  {auth.RoleReader},
+		"support-key": {auth.RoleSupport},
+		"ingest-key":  {auth.RoleIngest},
+		"admin-key":   {auth.RoleAdmin},
+	}
+	ord := testOrder()
+	body, _ := json.Marshal(ord)
+	h := newTestServer(t, cfg, ord)
+
+	reader := mintToken(t, testSecret, auth.RoleReader)
+	forged := mintToken(t, "other-secret", auth.RoleAdmin)
+
+	cases := []struct {
+		name         string
+		method, path string
+		header       [2]string
+		status       int
+	}{
+		{"public without credentials", "GET", "/healthz", [2]string{}, 200},
+		{"read without credentials", "GET", "/recent", [2]string{}, 401},
+		{"write without credentials", "POST", "/orders", [2]string{}, 401},
+		{"admin without credentials", "POST", "/admin/cache/warm", [2]string{}, 401},
+		{"unknown api key", "GET", "/recent", [2]string{"X-API-Key", "nope"}, 401},
+		{"forged token", "GET", "/recent", [2]string{"Authorization", "Bearer " + forged}, 401},
+		{"basic scheme", "GET", "/recent", [2]string{"Authorization", "Basic cmVhZGVyOmtleQ=="}, 401},
+		{"bad credential on a public route", "GET", "/healthz", [2]string{"X-API-Key", "nope"}, 401},
+
+		{"read as reader", "GET", "/recent", [2]string{"X-API-Key", "reader-key"}, 200},
+		{"read as reader token", "GET", "/recent", [2]string{"Authorization", "Bearer " + reader}, 200},
+		{"read as ingest", "GET", "/order/" + ord.OrderId.String(), [2]string{"X-API-Key", "ingest-key"}, 403},
+		{"write as reader", "POST", "/orders", [2]string{"X-API-Key", "reader-key"}, 403},
+		{"write as support", "POST", "/orders", [2]string{"X-API-Key", "support-key"}, 403},
+		{"write as ingest", "POST", "/orders", [2]string{"X-API-Key", "ingest-key"}, 201},
+		{"export as reader", "GET", "/orders/export", [2]string{"X-API-Key", "reader-key"}, 403},
+		{"export as support", "GET", "/orders/export", [2]string{"X-API-Key", "support-key"}, 200},
+		{"admin as reader", "POST", "/admin/cache/warm", [2]string{"X-API-Key", "reader-key"}, 403},
+		{"admin as ingest", "POST", "/admin/cache/warm", [2]string{"X-API-Key", "ingest-key"}, 403},
+		{"admin as admin", "POST", "/admin/cache/warm", [2]string{"X-API-Key", "admin-key"}, 202},
+
+		{"access_token jwt", "GET", "/recent?access_token=" + reader, [2]string{}, 200},
+		{"access_token api key", "GET", "/recent?access_token=reader-key", [2]string{}, 200},
+		{"access_token wrong role", "POST", "/admin/cache/warm?access_token=reader-key", [2]string{}, 403},
+		{"access_token forged", "GET", "/recent?access_token=" + forged, [2]string{}, 401},
+		{"access_token unknown", "GET", "/recent?access_token=nope", [2]string{}, 401},
+		{"header wins over access_token", "GET", "/recent?access_token=reader-key", [2]string{"X-API-Key", "nope"}, 401},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var req *http.Request
+			if c.method == "POST" && c.path == "/orders" {
+				req = httptest.NewRequest(c.method, c.path, strings.NewReader(string(body)))
+				req.Header.Set("Content-Type", "application/json")
+			} else {
+				req = httptest.NewRequest(c.method, c.path, nil)
+			}
+			if c.header[0] != "" {
+				req.Header.Set(c.header[0], c.header[1])
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != c.status {
+				t.Fatalf("status %d, want %d: %s", rec.Code, c.status, rec.Body)
+			}
+			if c.status == 401 && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without WWW-Authenticate")
+			}
+		})
+	}
+}
+
+// Malformed requests are not validated for callers that may not make them.
+func TestAuthBeforeValidation(t *testing.T) {
+	cfg := testConfig()
+	cfg.Auth.Enabled = true
+	cfg.Auth.APIKeys = map[string][]string{"reader-key": {auth.RoleReader}, "ingest-key": {auth.RoleIngest}}
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.Rules = map[string]string{"POST /orders": "1/m"}
+	cfg.Redis.Addr = "127.0.0.1:1"
+	h := newTestServer(t, cfg)
+
+	for _, c := range []struct {
+		name, key string
+		status    int
+	}{
+		{"anonymous", "", 401},
+		{"unknown key", "nope", 401},
+		{"reader", "reader-key", 403},
+		{"ingest", "ingest-key", 400},
+		{"ingest over its rate", "ingest-key", 429},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/orders", strings.NewReader(`{"order_uid": 1`))
+			req.Header.Set("Content-Type", "application/json")
+			if c.key != "" {
+				req.Header.Set("X-API-Key", c.key)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != c.status {
+				t.Fatalf("status %d, want %d: %s", rec.Code, c.status, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"order-service/internal/domain/entities"
+)
+
+type batchGetRequest struct {
+	IDs []string `json:"ids"`
+}
+
+type batchGetResponse struct {
+	Orders  []*entities.Order `json:"orders"`
+	Missing []string          `json:"missing"`
+}
+
+// handleBatchGet answers with every order of ids it can find and lists the
+// ones it cannot.
+func (s *Server) handleBatchGet(w http.ResponseWriter, r *http.Request) {
+	var req batchGetRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxOrderBody)).Decode(&req); err != nil {
+		s.logger(r).Warnw("bad batch-get body", "error", err)
+		writeStatus(w, r, http.StatusBadRequest, codeBadRequest, "bad json: "+err.Error())
+		return
+	}
+	if max := s.cfg.HTTP.BatchGetMax; len(req.IDs) > max {
+		writeStatus(w, r, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("at most %d ids per request", max))
+		return
+	}
+
+	orders, missing, err := s.uc.GetMany(r.Context(), req.IDs)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	for i, o := range orders {
+		orders[i] = s.mask.Apply(r.Context(), o)
+	}
+	if missing == nil {
+		missing = []string{}
+	}
+	s.writeJSON(w, r, http.StatusOK, batchGetResponse{Orders: orders, Missing: missing})
+}
@@ -0,0 +1,144 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"order-service/internal/domain/entities"
+	"order-service/internal/domain/export"
+)
+
+// flush the response every this many orders so clients see progress
+const exportFlushEvery = 200
+
+// handleExport streams orders of a date range as CSV or NDJSON. Headers go
+// out with the first byte, so failures until then are ordinary problem
+// responses. Once the first byte is out a failure can no longer become a
+// problem response, so the connection is aborted instead and the client
+// sees a broken transfer rather than a short file.
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format == "" {
+		format = export.FormatNDJSON
+	}
+	from, err := export.ParseTime(q.Get("from"))
+	if err != nil {
+		writeStatus(w, r, http.StatusBadRequest, codeBadRequest, "from: "+err.Error())
+		return
+	}
+	to, err := export.ParseTime(q.Get("to"))
+	if err != nil {
+		writeStatus(w, r, http.StatusBadRequest, codeBadRequest, "to: "+err.Error())
+		return
+	}
+	f := entities.OrderFilter{
+		CustomerId:      q.Get("customer"),
+		DeliveryService: q.Get("delivery_service"),
+		From:            from,
+		To:              to,
+	}
+
+	out := &exportStream{w: w, format: format, enc: negotiateEncoding(r.Header.Get("Accept-Encoding"))}
+	ew, err := export.NewWriter(format, out)
+	if err != nil {
+		writeStatus(w, r, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	n := 0
+	err = s.uc.Export(r.Context(), f, func(o *entities.Order) error {
+		if err := ew.Write(s.mask.Apply(r.Context(), o)); err != nil {
+			return err
+		}
+		if n++; n%exportFlushEvery == 0 {
+			if err := ew.Close(); err != nil {
+				return err
+			}
+			return out.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		if err = ew.Close(); err == nil {
+			err = out.Close()
+		}
+	}
+	switch {
+	case err == nil:
+		s.logger(r).Infow("export done", "orders", n, "format", format)
+	case !out.started():
+		s.writeError(w, r, err)
+	default:
+		s.logger(r).Errorw("export aborted", "orders", n, "error", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// exportStream commits the response headers, including Content-Encoding,
+// on the first write.
+type exportStream struct {
+	w      http.ResponseWriter
+	format string
+	enc    string
+	zw     resetWriter
+	out    io.Writer
+}
+
+func (e *exportStream) started() bool { return e.out != nil }
+
+func (e *exportStream) start() {
+	h := e.w.Header()
+	h.Set("Content-Type", export.ContentType(e.format))
+	h.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="orders-%s.%s"`, time.Now().UTC().Format("20060102T150405Z"), e.format))
+	h.Set("Cache-Control", "no-store")
+	h.Add("Vary", "Accept-Encoding")
+	e.out = e.w
+	if e.enc != "" {
+		e.zw = encoders[e.enc].Get().(resetWriter)
+		e.zw.Reset(e.w)
+		e.out = e.zw
+		h.Set("Content-Encoding", e.enc)
+	}
+	e.w.WriteHeader(http.StatusOK)
+}
+
+func (e *exportStream) Write(p []byte) (int, error) {
+	if !e.started() {
+		e.start()
+	}
+	return e.out.Write(p)
+}
+
+// Flush pushes what was written so far to the client.
+func (e *exportStream) Flush() error {
+	if !e.started() {
+		return nil
+	}
+	if zf, ok := e.zw.(interface{ Flush() error }); ok {
+		if err := zf.Flush(); err != nil {
+			return err
+		}
+	}
+	if f, ok := e.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// Close ends the compressed stream. An export without a single byte still
+// gets its headers.
+func (e *exportStream) Close() error {
+	if !e.started() {
+		e.start()
+	}
+	if e.zw == nil {
+		return nil
+	}
+	err := e.zw.Close()
+	encoders[e.enc].Put(e.zw)
+	e.zw = nil
+	return err
+}
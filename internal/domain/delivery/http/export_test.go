@@ -0,0 +1,110 @@
+package http
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExportFailsBeforeFirstByte(t *testing.T) {
+	down := newMemRepo()
+	down.exportErr = errors.New("dial tcp 10.0.0.5:5432: connect: connection refused")
+
+	cases := []struct {
+		name   string
+		repo   *memRepo
+		query  string
+		status int
+	}{
+		{"unknown format", newMemRepo(testOrder()), "format=xml", 400},
+		{"bad from", newMemRepo(testOrder()), "from=yesterday", 400},
+		{"bad to", newMemRepo(testOrder()), "format=csv&to=2021-13-01", 400},
+		{"storage down", down, "format=csv", 503},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newServerOver(t, testConfig(), c.repo)
+			req := httptest.NewRequest("GET", "/orders/export?"+c.query, nil)
+			req.Header.Set("Accept-Encoding", "gzip, br")
+			rec := httptest.NewRecorder()
+			s.handleExport(rec, req)
+
+			if rec.Code != c.status {
+				t.Fatalf("status %d, want %d: %s", rec.Code, c.status, rec.Body)
+			}
+			if ce := rec.Header().Get("Content-Encoding"); ce != "" {
+				t.Errorf("problem response labelled Content-Encoding %q", ce)
+			}
+			var p Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+				t.Fatalf("body is not a plain problem: %v: %q", err, rec.Body)
+			}
+			if strings.Contains(p.Detail, "10.0.0.5") {
+				t.Errorf("storage error leaked: %q", p.Detail)
+			}
+		})
+	}
+}
+
+func TestExportAbortsAfterFirstByte(t *testing.T) {
+	repo := newMemRepo(testOrder())
+	repo.exportErr = errors.New("connection reset")
+	s := newServerOver(t, testConfig(), repo)
+	req := httptest.NewRequest("GET", "/orders/export", nil)
+	rec := httptest.NewRecorder()
+
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Fatalf("recovered %v, want http.ErrAbortHandler", v)
+		}
+		if rec.Code != 200 || rec.Body.Len() == 0 {
+			t.Errorf("status %d with %d bytes, want the stream started", rec.Code, rec.Body.Len())
+		}
+	}()
+	s.handleExport(rec, req)
+}
+
+func TestExportCompressed(t *testing.T) {
+	cases := []struct {
+		name, query, want string
+		orders            int
+	}{
+		{"ndjson", "format=ndjson", `"order_uid":"` + testOrder().OrderId.String(), 1},
+		{"csv", "format=csv", "order_uid,track_number", 1},
+		{"empty ndjson", "format=ndjson", "", 0},
+		{"empty csv", "format=csv", "order_uid,track_number", 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repo := newMemRepo()
+			if c.orders > 0 {
+				repo = newMemRepo(testOrder())
+			}
+			s := newServerOver(t, testConfig(), repo)
+			req := httptest.NewRequest("GET", "/orders/export?"+c.query, nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+			s.handleExport(rec, req)
+
+			if rec.Code != 200 || rec.Header().Get("Content-Encoding") != "gzip" {
+				t.Fatalf("status %d, Content-Encoding %q", rec.Code, rec.Header().Get("Content-Encoding"))
+			}
+			zr, err := gzip.NewReader(rec.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := io.ReadAll(zr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(body), c.want) {
+				t.Errorf("body %q, want it to contain %q", body, c.want)
+			}
+		})
+	}
+}
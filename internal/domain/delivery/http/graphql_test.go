@@ -0,0 +1,83 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"order-service/internal/domain/auth"
+)
+
+func TestGraphQLHidesStorageErrors(t *testing.T) {
+	ord := testOrder()
+	dbErr := errors.New(`failed to connect to host=10.0.0.5 user=orders: password authentication failed`)
+
+	cases := []struct {
+		name   string
+		fail   string
+		query  string
+		errMsg string
+	}{
+		{"order", "Find", `{ order(order_uid: "` + ord.OrderId.String() + `") { track_number } }`, "storage unavailable"},
+		{"batch loader", "DeliveriesByOrders", `{ orders { nodes { order_uid delivery { city } } } }`, "storage unavailable"},
+		{"invalid id", "", `{ order(order_uid: "nope") { track_number } }`, "invalid order id"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repo := newMemRepo(ord)
+			if c.fail != "" {
+				repo.fail = map[string]error{c.fail: dbErr}
+			}
+			s := newServerOver(t, testConfig(), repo)
+			body, _ := json.Marshal(map[string]string{"query": c.query})
+			req := httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+			rec := httptest.NewRecorder()
+			s.gql.ServeHTTP(rec, req)
+
+			var resp struct {
+				Errors []struct{ Message string }
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Errors) == 0 || !strings.Contains(resp.Errors[0].Message, c.errMsg) {
+				t.Fatalf("errors %+v, want %q", resp.Errors, c.errMsg)
+			}
+			if strings.Contains(rec.Body.String(), "10.0.0.5") {
+				t.Errorf("storage error leaked: %s", rec.Body)
+			}
+		})
+	}
+}
+
+func TestGraphQLHiddenFilter(t *testing.T) {
+	s := newServerOver(t, testConfig(), newMemRepo(testOrder()))
+	for _, c := range []struct {
+		role   string
+		denied bool
+	}{
+		{auth.RoleReader, true},
+		{auth.RoleAdmin, false},
+	} {
+		t.Run(c.role, func(t *testing.T) {
+			body, _ := json.Marshal(map[string]string{"query": `{ orders(filter: {email: "test@gmail.com"}) { nodes { order_uid } } }`})
+			req := httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: "t", Roles: []string{c.role}}))
+			rec := httptest.NewRecorder()
+			s.gql.ServeHTTP(rec, req)
+
+			var resp struct {
+				Data   json.RawMessage
+				Errors []struct{ Message string }
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if denied := len(resp.Errors) > 0; denied != c.denied {
+				t.Fatalf("errors %+v, want denied %v", resp.Errors, c.denied)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// handleLive answers as long as the process serves HTTP at all.
+func (s *Server) handleLive(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write([]byte(`{"status":"ok"}` + "\n"))
+}
+
+// handleReady probes the dependencies; 503 when a critical one is down.
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	rep := s.health.Run(r.Context())
+	status := http.StatusOK
+	if !rep.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(rep)
+}
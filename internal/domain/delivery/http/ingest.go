@@ -0,0 +1,28 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"order-service/internal/domain/entities"
+)
+
+const maxOrderBody = 1 << 20
+
+func (s *Server) handleIngest(w http.ResponseWriter, r *http.Request) {
+	var o entities.Order
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxOrderBody)).Decode(&o); err != nil {
+		s.logger(r).Warnw("bad order body", "error", err)
+		writeStatus(w, r, http.StatusBadRequest, codeBadRequest, "bad json: "+err.Error())
+		return
+	}
+
+	id := o.OrderId.String()
+	if err := s.uc.Set(r.Context(), &o); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/order/"+id)
+	w.WriteHeader(http.StatusCreated)
+}
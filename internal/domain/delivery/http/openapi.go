@@ -0,0 +1,468 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"order-service/config"
+	"order-service/internal/domain/entities"
+	"order-service/internal/domain/export"
+	"order-service/internal/domain/health"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/openapi3gen"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// routes served by the router but deliberately left out of the spec
+var undocumentedRoutes = map[string]bool{
+	"GET /":     true,
+	"GET /docs": true,
+	// Prometheus text format, not JSON
+	"GET /metrics": true,
+}
+
+func init() {
+	openapi3.DefineStringFormatCallback("uuid", func(s string) error {
+		_, err := uuid.Parse(s)
+		return err
+	})
+}
+
+// buildSpec describes the HTTP API. Schemas are generated from the entities
+// so a changed JSON tag shows up here without editing the document.
+func buildSpec(cfg *config.ConfigModel) (*openapi3.T, error) {
+	schemas := openapi3.Schemas{}
+	gen := openapi3gen.NewGenerator(
+		openapi3gen.CreateComponentSchemas(openapi3gen.ExportComponentSchemasOptions{
+			ExportComponentSchemas: true,
+			ExportTopLevelSchema:   true,
+		}),
+		openapi3gen.SchemaCustomizer(func(_ string, t reflect.Type, _ reflect.StructTag, s *openapi3.Schema) error {
+			if t == reflect.TypeOf(uuid.UUID{}) {
+				*s = *openapi3.NewStringSchema().WithFormat("uuid")
+			}
+			return nil
+		}),
+	)
+	orderRef, err := gen.NewSchemaRefForValue(&entities.Order{}, schemas)
+	if err != nil {
+		return nil, fmt.Errorf("order schema: %w", err)
+	}
+
+	problemRef, err := gen.NewSchemaRefForValue(&Problem{}, schemas)
+	if err != nil {
+		return nil, fmt.Errorf("problem schema: %w", err)
+	}
+
+	uidParam := &openapi3.ParameterRef{Value: openapi3.NewPathParameter("uid").
+		WithDescription("order_uid").
+		WithSchema(openapi3.NewStringSchema().WithFormat("uuid"))}
+
+	// parameters and responses of routes answered by writeCacheable
+	cacheParams := openapi3.Parameters{
+		{Value: openapi3.NewQueryParameter("pretty").
+			WithDescription("false for compact JSON").
+			WithSchema(openapi3.NewBoolSchema())},
+		{Value: openapi3.NewHeaderParameter("If-None-Match").WithSchema(openapi3.NewStringSchema())},
+	}
+	notModified := &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("ETag matches If-None-Match")}
+
+	problem := func(desc string) *openapi3.ResponseRef {
+		return &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription(desc).
+			WithContent(openapi3.Content{"application/problem+json": openapi3.NewMediaType().WithSchemaRef(problemRef)})}
+	}
+	jsonResp := func(desc string, schema *openapi3.SchemaRef) *openapi3.ResponseRef {
+		return &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription(desc).
+			WithContent(openapi3.NewContentWithJSONSchemaRef(schema))}
+	}
+	responses := func(pairs ...any) *openapi3.Responses {
+		rs := openapi3.NewResponses()
+		rs.Delete("default")
+		for i := 0; i < len(pairs); i += 2 {
+			rs.Set(pairs[i].(string), pairs[i+1].(*openapi3.ResponseRef))
+		}
+		return rs
+	}
+
+	doc := &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info: &openapi3.Info{
+			Title:   "order-service",
+			Version: "1.0.0",
+		},
+		Paths: openapi3.NewPaths(),
+		Components: &openapi3.Components{
+			Schemas: schemas,
+			SecuritySchemes: openapi3.SecuritySchemes{
+				"apiKey": {Value: openapi3.NewSecurityScheme().WithType("apiKey").WithIn("header").WithName("X-API-Key")},
+				"bearer": {Value: openapi3.NewJWTSecurityScheme()},
+			},
+		},
+	}
+
+	// every operation except the document itself needs one of these when
+	// AUTH_ENABLED is set, and is rate limited
+	secured := func(op *openapi3.Operation, roles ...string) *openapi3.Operation {
+		op.Security = &openapi3.SecurityRequirements{
+			openapi3.NewSecurityRequirement().Authenticate("apiKey"),
+			openapi3.NewSecurityRequirement().Authenticate("bearer"),
+		}
+		op.Description = strings.TrimSpace(op.Description + "\n\nRoles: " + strings.Join(roles, ", "))
+		op.Responses.Set("401", problem("missing or invalid credentials"))
+		op.Responses.Set("403", problem("caller lacks the required role"))
+		op.Responses.Set("429", problem("rate limit exceeded; wait Retry-After seconds"))
+		return op
+	}
+	read := func(op *openapi3.Operation) *openapi3.Operation { return secured(op, readRoles...) }
+
+	doc.AddOperation("/recent", http.MethodGet, read(&openapi3.Operation{
+		OperationID: "recentOrders",
+		Summary:     "IDs of the most recently created orders",
+		Parameters:  cacheParams,
+		Responses: responses(
+			"200", jsonResp("order ids, newest first",
+				openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema().WithFormat("uuid")).NewRef()),
+			"304", notModified,
+			"500", problem("internal error"),
+			"503", problem("storage unavailable"),
+		),
+	}))
+
+	doc.AddOperation("/order/{uid}", http.MethodGet, read(&openapi3.Operation{
+		OperationID: "getOrder",
+		Summary:     "Order with delivery, payment and items",
+		Parameters:  append(openapi3.Parameters{uidParam}, cacheParams...),
+		Responses: responses(
+			"200", jsonResp("order", orderRef),
+			"304", notModified,
+			"400", problem("bad id"),
+			"404", problem("not found"),
+			"500", problem("internal error"),
+			"503", problem("storage unavailable"),
+		),
+	}))
+
+	doc.AddOperation("/order/{uid}/watch", http.MethodGet, read(&openapi3.Operation{
+		OperationID: "watchOrder",
+		Summary:     "WebSocket pushing the order each time it is saved",
+		Parameters:  openapi3.Parameters{uidParam},
+		Responses: responses(
+			"101", &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("switching to websocket; messages are Order JSON")},
+			"400", problem("bad id"),
+			"503", problem("too many connections"),
+		),
+	}))
+
+	doc.AddOperation("/orders/stream", http.MethodGet, read(&openapi3.Operation{
+		OperationID: "streamOrders",
+		Summary:     "Server-sent events of newly saved orders",
+		Parameters: openapi3.Parameters{
+			{Value: openapi3.NewQueryParameter("customer").WithSchema(openapi3.NewStringSchema())},
+			{Value: openapi3.NewQueryParameter("delivery_service").WithSchema(openapi3.NewStringSchema())},
+			{Value: openapi3.NewQueryParameter("min_amount").WithSchema(openapi3.NewInt64Schema())},
+			{Value: openapi3.NewQueryParameter("last_event_id").WithSchema(openapi3.NewInt64Schema().WithMin(0))},
+			{Value: openapi3.NewHeaderParameter("Last-Event-ID").WithSchema(openapi3.NewInt64Schema().WithMin(0))},
+		},
+		Responses: responses(
+			"200", &openapi3.ResponseRef{Value: openapi3.NewResponse().
+				WithDescription("event stream; each event carries an Order as data").
+				WithContent(openapi3.NewContentWithSchema(openapi3.NewStringSchema(), []string{"text/event-stream"}))},
+			"400", problem("bad filter"),
+		),
+	}))
+
+	batchRequest := openapi3.NewObjectSchema().
+		WithProperty("ids", openapi3.NewArraySchema().
+			WithItems(openapi3.NewStringSchema().WithFormat("uuid")).
+			WithMinItems(1).
+			WithMaxItems(int64(cfg.HTTP.BatchGetMax)))
+	batchRequest.Required = []string{"ids"}
+	orderList := openapi3.NewArraySchema()
+	orderList.Items = orderRef
+	batchResponse := openapi3.NewObjectSchema().
+		WithProperty("orders", orderList).
+		WithProperty("missing", openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema().WithFormat("uuid")))
+	batchResponse.Required = []string{"orders", "missing"}
+	doc.AddOperation("/orders/batch-get", http.MethodPost, read(&openapi3.Operation{
+		OperationID: "batchGetOrders",
+		Summary:     "Several orders by id in one call",
+		Description: fmt.Sprintf("At most %d ids. Orders come in request order; unknown ids are listed under missing.", cfg.HTTP.BatchGetMax),
+		Parameters:  cacheParams[:1],
+		RequestBody: &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().WithRequired(true).WithJSONSchema(batchRequest)},
+		Responses: responses(
+			"200", jsonResp("found orders and missing ids", batchResponse.NewRef()),
+			"400", problem("bad json, bad id or too many ids"),
+			"500", problem("internal error"),
+			"503", problem("storage unavailable"),
+		),
+	}))
+
+	gqlRequest := openapi3.NewObjectSchema().
+		WithProperty("query", openapi3.NewStringSchema()).
+		WithProperty("operationName", openapi3.NewStringSchema()).
+		WithProperty("variables", openapi3.NewObjectSchema().WithAnyAdditionalProperties())
+	gqlRequest.Required = []string{"query"}
+	doc.AddOperation("/graphql", http.MethodPost, read(&openapi3.Operation{
+		OperationID: "graphql",
+		Summary:     "GraphQL queries over orders",
+		RequestBody: &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().WithRequired(true).WithJSONSchema(gqlRequest)},
+		Responses: responses(
+			"200", jsonResp("GraphQL result", openapi3.NewObjectSchema().WithAnyAdditionalProperties().NewRef()),
+			"400", problem("body does not match the schema"),
+		),
+	}))
+
+	doc.AddOperation("/orders", http.MethodPost, secured(&openapi3.Operation{
+		OperationID: "ingestOrder",
+		Summary:     "Save an order as if it came from Kafka",
+		RequestBody: &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().WithRequired(true).WithSchemaRef(orderRef, []string{"application/json"})},
+		Responses: responses(
+			"201", &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("saved; Location points to the order")},
+			"400", problem("bad json"),
+			"422", problem("order failed validation"),
+			"503", problem("storage unavailable"),
+		),
+	}, writeRoles...))
+
+	doc.AddOperation("/orders/export", http.MethodGet, secured(&openapi3.Operation{
+		OperationID: "exportOrders",
+		Summary:     "Stream orders of a date range as CSV (one row per item) or NDJSON (one order per line)",
+		Parameters: openapi3.Parameters{
+			{Value: openapi3.NewQueryParameter("format").WithSchema(openapi3.NewStringSchema().WithEnum(export.FormatCSV, export.FormatNDJSON))},
+			{Value: openapi3.NewQueryParameter("from").WithDescription("inclusive; RFC 3339 or YYYY-MM-DD").WithSchema(openapi3.NewStringSchema())},
+			{Value: openapi3.NewQueryParameter("to").WithDescription("exclusive; RFC 3339 or YYYY-MM-DD").WithSchema(openapi3.NewStringSchema())},
+			{Value: openapi3.NewQueryParameter("customer").WithSchema(openapi3.NewStringSchema())},
+			{Value: openapi3.NewQueryParameter("delivery_service").WithSchema(openapi3.NewStringSchema())},
+		},
+		Responses: responses(
+			"200", &openapi3.ResponseRef{Value: openapi3.NewResponse().
+				WithDescription("export file; a transfer cut short means the export failed").
+				WithContent(openapi3.NewContentWithSchema(openapi3.NewStringSchema(), []string{"text/csv", "application/x-ndjson"}))},
+			"400", problem("bad format or range"),
+		),
+	}, exportRoles...))
+
+	doc.AddOperation("/admin/cache/warm", http.MethodPost, secured(&openapi3.Operation{
+		OperationID: "warmCache",
+		Summary:     "Reload the most recent orders into the cache",
+		Responses: responses(
+			"202", &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("warm-up started")},
+		),
+	}, adminRoles...))
+
+	doc.AddOperation("/openapi.json", http.MethodGet, &openapi3.Operation{
+		OperationID: "openapi",
+		Summary:     "This document",
+		Responses: responses(
+			"200", jsonResp("OpenAPI 3 document", openapi3.NewObjectSchema().WithAnyAdditionalProperties().NewRef()),
+		),
+	})
+
+	reportRef, err := gen.NewSchemaRefForValue(&health.Report{}, schemas)
+	if err != nil {
+		return nil, fmt.Errorf("health schema: %w", err)
+	}
+	doc.AddOperation("/healthz", http.MethodGet, &openapi3.Operation{
+		OperationID: "live",
+		Summary:     "Liveness: the process is up",
+		Responses: responses(
+			"200", jsonResp("alive", openapi3.NewObjectSchema().WithProperty("status", openapi3.NewStringSchema()).NewRef()),
+		),
+	})
+	doc.AddOperation("/readyz", http.MethodGet, &openapi3.Operation{
+		OperationID: "ready",
+		Summary:     "Readiness: status of Postgres, Redis and Kafka",
+		Responses: responses(
+			"200", jsonResp("ok or degraded (only non-critical checks failed)", reportRef),
+			"503", jsonResp("a critical check failed", reportRef),
+		),
+	})
+
+	if err := openapi3.NewLoader().ResolveRefsIn(doc, nil); err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+	return doc, nil
+}
+
+// checkSpec reports routes registered on r and missing from doc and the
+// other way round.
+func checkSpec(r chi.Routes, doc *openapi3.T) error {
+	registered := map[string]bool{}
+	if err := chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		key := method + " " + strings.TrimSuffix(route, "/")
+		if route == "/" {
+			key = method + " /"
+		}
+		if !undocumentedRoutes[key] {
+			registered[key] = true
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	documented := map[string]bool{}
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	var drift []string
+	for k := range registered {
+		if !documented[k] {
+			drift = append(drift, "undocumented route "+k)
+		}
+	}
+	for k := range documented {
+		if !registered[k] {
+			drift = append(drift, "no handler for "+k)
+		}
+	}
+	if len(drift) > 0 {
+		sort.Strings(drift)
+		return fmt.Errorf("openapi drift: %s", strings.Join(drift, "; "))
+	}
+	return nil
+}
+
+// validateAPI rejects requests that do not match the spec, reading at most
+// maxOrderBody of the body, and, when responses is set, logs JSON responses
+// that do not match it either.
+func (s *Server) validateAPI(doc *openapi3.T, responses bool) (func(http.Handler) http.Handler, error) {
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+	opts := &openapi3filter.Options{
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		MultiError:         true,
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route, params, err := router.FindRoute(r)
+			if err != nil {
+				// not an API route (static pages) or unknown path: let chi answer
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, maxOrderBody)
+			}
+			in := &openapi3filter.RequestValidationInput{
+				Request:    r,
+				PathParams: params,
+				Route:      route,
+				Options:    opts,
+			}
+			if err := openapi3filter.ValidateRequest(r.Context(), in); err != nil {
+				s.logger(r).Warnw("request does not match spec", "path", r.URL.Path, "error", err)
+				code, detail := codeBadRequest, firstLine(err.Error())
+				var (
+					re *openapi3filter.RequestError
+					me *http.MaxBytesError
+				)
+				switch {
+				case errors.As(err, &me):
+					detail = fmt.Sprintf("request body over %d bytes", me.Limit)
+				case errors.As(err, &re) && re.Parameter != nil && re.Parameter.Name == "uid":
+					code = codeInvalidID
+				}
+				writeStatus(w, r, http.StatusBadRequest, code, detail)
+				return
+			}
+
+			if !responses || !jsonOnly(route) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rec := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			out := &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: in,
+				Status:                 rec.status,
+				Header:                 rec.Header(),
+				Options:                opts,
+			}
+			body, err := decodedBody(rec.Header().Get("Content-Encoding"), rec.body.Bytes())
+			if err != nil {
+				s.logger(r).Errorw("response body not decodable", "path", r.URL.Path, "error", err)
+				return
+			}
+			out.SetBodyBytes(body)
+			if err := openapi3filter.ValidateResponse(r.Context(), out); err != nil {
+				s.logger(r).Errorw("response does not match spec", "path", r.URL.Path, "status", rec.status, "error", err)
+			}
+		})
+	}, nil
+}
+
+// jsonOnly reports whether every documented response of the route is JSON,
+// i.e. it is safe to buffer.
+func jsonOnly(route *routers.Route) bool {
+	for _, rr := range route.Operation.Responses.Map() {
+		if rr.Value == nil {
+			continue
+		}
+		for ct := range rr.Value.Content {
+			if ct != "application/json" && ct != "application/problem+json" {
+				return false
+			}
+		}
+	}
+	return route.Operation.OperationID != "watchOrder"
+}
+
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (s *Server) serveSpec(doc *openapi3.T) http.HandlerFunc {
+	b, err := json.MarshalIndent(doc, "", "  ")
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		http.ServeContent(w, r, "openapi.json", time.Time{}, bytes.NewReader(b))
+	}
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,213 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/go-chi/chi/v5"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/openapi.json from buildSpec")
+
+const goldenSpec = "testdata/openapi.json"
+
+func TestRoutesMatchSpec(t *testing.T) {
+	cfg := testConfig()
+	spec, err := buildSpec(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSpec(newTestServer(t, cfg).(chi.Routes), spec); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSpecUnchanged pins the generated document: schemas come from the
+// entities, so a renamed or retyped field changes the API without anyone
+// editing the spec. Run with -update when the change is intended.
+func TestSpecUnchanged(t *testing.T) {
+	spec, err := buildSpec(testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(goldenSpec), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(goldenSpec, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(goldenSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("the API changed against %s; if that is intended, run go test -run TestSpecUnchanged -update", goldenSpec)
+	}
+}
+
+// TestResponsesMatchSpec calls the handlers and validates what they send
+// against the pinned document, with every order field required and no
+// others allowed, so a handler or entity drifting from it fails here.
+func TestResponsesMatchSpec(t *testing.T) {
+	doc, err := openapi3.NewLoader().LoadFromFile(goldenSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Order", "Delivery", "Payment", "Item"} {
+		ref, ok := doc.Components.Schemas[name]
+		if !ok {
+			t.Fatalf("no %s schema in %s", name, goldenSpec)
+		}
+		strict(ref.Value)
+	}
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ord := testOrder()
+	valid, _ := json.Marshal(ord)
+	invalid := strings.Replace(string(valid), ord.TrackNumber, strings.Repeat("X", 40), 1)
+	unknown := "00000000-0000-4000-8000-000000000000"
+	h := newTestServer(t, testConfig(), ord)
+
+	cases := []struct {
+		method, path, body string
+		status             int
+	}{
+		{"GET", "/recent", "", 200},
+		{"GET", "/order/" + ord.OrderId.String(), "", 200},
+		{"GET", "/order/" + unknown, "", 404},
+		{"GET", "/order/not-a-uuid", "", 400},
+		{"POST", "/orders/batch-get", `{"ids":["` + ord.OrderId.String() + `","` + unknown + `"]}`, 200},
+		{"POST", "/orders", string(valid), 201},
+		{"POST", "/orders", invalid, 422},
+		{"POST", "/graphql", `{"query":"{ __typename }"}`, 200},
+		{"POST", "/admin/cache/warm", "", 202},
+		{"GET", "/healthz", "", 200},
+		{"GET", "/readyz", "", 200},
+		{"GET", "/openapi.json", "", 200},
+	}
+	seen := map[string]bool{}
+	for _, c := range cases {
+		t.Run(c.method+" "+c.path+" "+http.StatusText(c.status), func(t *testing.T) {
+			req := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+			if c.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != c.status {
+				t.Fatalf("status %d, want %d: %s", rec.Code, c.status, rec.Body)
+			}
+
+			route, params, err := router.FindRoute(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seen[strings.ToUpper(route.Method)+" "+route.Path] = true
+			in := &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: &openapi3filter.RequestValidationInput{
+					Request:    req,
+					PathParams: params,
+					Route:      route,
+					Options:    &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc},
+				},
+				Status:  rec.Code,
+				Header:  rec.Header(),
+				Options: &openapi3filter.Options{MultiError: true},
+			}
+			in.SetBodyBytes(rec.Body.Bytes())
+			if err := openapi3filter.ValidateResponse(context.Background(), in); err != nil {
+				t.Fatalf("response does not match %s: %v", goldenSpec, err)
+			}
+		})
+	}
+
+	// streams, websockets and the export are not JSON; every other
+	// documented operation must be exercised above
+	skip := map[string]bool{"GET /orders/stream": true, "GET /order/{uid}/watch": true, "GET /orders/export": true}
+	var untested []string
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			if k := method + " " + path; !seen[k] && !skip[k] {
+				untested = append(untested, k)
+			}
+		}
+	}
+	sort.Strings(untested)
+	if len(untested) > 0 {
+		t.Errorf("no response checked for %v", untested)
+	}
+}
+
+// strict requires every property of s and rejects any other, through
+// nested objects and arrays.
+func strict(s *openapi3.Schema) {
+	if s == nil {
+		return
+	}
+	if s.Items != nil {
+		strict(s.Items.Value)
+	}
+	if len(s.Properties) == 0 {
+		return
+	}
+	s.Required = s.Required[:0]
+	for name, p := range s.Properties {
+		s.Required = append(s.Required, name)
+		strict(p.Value)
+	}
+	sort.Strings(s.Required)
+	no := false
+	s.AdditionalProperties = openapi3.AdditionalProperties{Has: &no}
+}
+
+func TestValidationBodyLimit(t *testing.T) {
+	h := newTestServer(t, testConfig())
+	ids := strings.Repeat(`"00000000-0000-4000-8000-000000000000",`, maxOrderBody/38+1)
+	body := &countingReader{r: strings.NewReader(`{"ids":[` + ids + `"00000000-0000-4000-8000-000000000000"]}`)}
+	req := httptest.NewRequest("POST", "/orders/batch-get", body)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "request body over") {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if body.n > maxOrderBody+1<<16 {
+		t.Errorf("read %d bytes of an oversized body", body.n)
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
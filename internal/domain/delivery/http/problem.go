@@ -0,0 +1,110 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"order-service/internal/domain/entities"
+	"order-service/internal/domain/logctx"
+)
+
+const problemType = "urn:order-service:problem:"
+
+// Problem is an RFC 7807 body. Code is the stable value clients branch on;
+// Type carries the same value as a URI.
+type Problem struct {
+	Type      string   `json:"type"`
+	Title     string   `json:"title"`
+	Status    int      `json:"status"`
+	Detail    string   `json:"detail,omitempty"`
+	Instance  string   `json:"instance,omitempty"`
+	Code      string   `json:"code"`
+	RequestID string   `json:"request_id,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+const (
+	codeNotFound    = "not_found"
+	codeInvalidID   = "invalid_id"
+	codeValidation  = "validation_failed"
+	codeUnavailable = "unavailable"
+	codeBadRequest  = "bad_request"
+	codeInternal    = "internal"
+	codeCanceled    = "canceled"
+)
+
+var problemTitles = map[string]string{
+	codeNotFound:     "Order not found",
+	codeInvalidID:    "Invalid order id",
+	codeValidation:   "Validation failed",
+	codeUnavailable:  "Service unavailable",
+	codeBadRequest:   "Bad request",
+	codeInternal:     "Internal error",
+	codeCanceled:     "Request canceled",
+	codeUnauthorized: "Authentication required",
+	codeForbidden:    "Forbidden",
+	codeRateLimited:  "Too many requests",
+}
+
+// classify maps a use case error to a status code and problem code.
+func classify(err error) (int, string) {
+	switch {
+	case errors.Is(err, entities.ErrNotFound):
+		return http.StatusNotFound, codeNotFound
+	case errors.Is(err, entities.ErrInvalidID):
+		return http.StatusBadRequest, codeInvalidID
+	case errors.Is(err, entities.ErrValidation):
+		return http.StatusUnprocessableEntity, codeValidation
+	case errors.Is(err, entities.ErrUnavailable):
+		return http.StatusServiceUnavailable, codeUnavailable
+	case errors.Is(err, context.Canceled):
+		// nginx convention; the client is gone anyway
+		return 499, codeCanceled
+	default:
+		return http.StatusInternalServerError, codeInternal
+	}
+}
+
+// writeError answers with the problem matching err. Details of internal
+// and storage errors stay in the logs.
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status, code := classify(err)
+	p := Problem{Status: status, Code: code}
+	switch code {
+	case codeInvalidID, codeNotFound:
+		p.Detail = err.Error()
+	case codeValidation:
+		p.Detail = entities.ErrValidation.Error()
+		var ve *entities.ValidationError
+		if errors.As(err, &ve) {
+			p.Errors = ve.Problems
+		}
+	}
+	if status >= 500 {
+		logctx.From(r.Context(), s.log).Errorw("request failed", "path", r.URL.Path, "error", err)
+	}
+	writeProblem(w, r, p)
+}
+
+// writeStatus answers with a problem of the given code and free-form detail.
+func writeStatus(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	writeProblem(w, r, Problem{Status: status, Code: code, Detail: detail})
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, p Problem) {
+	p.Type = problemType + p.Code
+	if p.Title == "" {
+		p.Title = problemTitles[p.Code]
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	p.Instance = r.URL.Path
+	p.RequestID = logctx.RequestID(r.Context())
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,66 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"order-service/internal/domain/auth"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const codeRateLimited = "rate_limited"
+
+// rateLimit counts the request against the caller's bucket for the matched
+// route. It must sit inside a route group so the route pattern is known.
+func (s *Server) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.limiter.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		route := r.Method + " " + chi.RouteContext(r.Context()).RoutePattern()
+		rule := s.limiter.RuleFor(route)
+		if rule.Unlimited() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		client := s.clientKey(r)
+		res := s.limiter.Allow(r.Context(), route, client, rule)
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(int(res.Reset.Seconds())))
+		h.Set("RateLimit-Policy", strconv.Itoa(rule.Burst)+";w="+strconv.Itoa(int(float64(rule.Burst)/rule.Rate)))
+		if !res.Allowed {
+			s.logger(r).Warnw("rate limited", "route", route, "client", client)
+			h.Set("Retry-After", strconv.Itoa(int(res.RetryAfter.Seconds())))
+			writeStatus(w, r, http.StatusTooManyRequests, codeRateLimited, "rate limit exceeded, retry in "+h.Get("Retry-After")+"s")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientKey identifies the caller: authenticated callers by subject, so a
+// key shared by several hosts shares one bucket, everyone else by address.
+func (s *Server) clientKey(r *http.Request) string {
+	if p := auth.FromContext(r.Context()); p != nil {
+		return p.Method + ":" + p.Subject
+	}
+	if s.cfg.RateLimit.TrustProxy {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			ip, _, _ := strings.Cut(xff, ",")
+			return "ip:" + strings.TrimSpace(ip)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
@@ -0,0 +1,187 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Bodies smaller than this go out uncompressed; the encoding overhead eats
+// the saving.
+const minCompressSize = 512
+
+var encoders = map[string]*sync.Pool{
+	"br": {New: func() any { return brotli.NewWriterLevel(nil, brotli.DefaultCompression) }},
+	"gzip": {New: func() any {
+		w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return w
+	}},
+}
+
+type resetWriter interface {
+	io.WriteCloser
+	Reset(io.Writer)
+}
+
+// writeCacheable sends v as JSON with a strong ETag over the body, answers
+// If-None-Match with 304 and compresses the body if the client accepts it.
+// Responses are per caller (masking), hence private and Vary on credentials.
+// ?pretty=false drops the indentation.
+func (s *Server) writeCacheable(w http.ResponseWriter, r *http.Request, v any) {
+	body, err := marshal(r, v)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	tag := base64.RawURLEncoding.EncodeToString(sum[:18])
+	enc := encodingFor(r, body)
+
+	h := w.Header()
+	h.Set("ETag", etag(tag, enc))
+	h.Set("Cache-Control", s.cacheControl())
+	h.Add("Vary", "Accept-Encoding, Authorization, X-API-Key")
+
+	if etagMatches(r.Header.Get("If-None-Match"), tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeBody(w, http.StatusOK, enc, body)
+}
+
+// writeJSON is writeCacheable for responses that must not be cached.
+func (s *Server) writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	body, err := marshal(r, v)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Add("Vary", "Accept-Encoding")
+	writeBody(w, status, encodingFor(r, body), body)
+}
+
+func marshal(r *http.Request, v any) ([]byte, error) {
+	if pretty, err := strconv.ParseBool(r.URL.Query().Get("pretty")); err == nil && !pretty {
+		return json.Marshal(v)
+	}
+	return json.MarshalIndent(v, "", "  ")
+}
+
+func encodingFor(r *http.Request, body []byte) string {
+	if len(body) < minCompressSize {
+		return ""
+	}
+	return negotiateEncoding(r.Header.Get("Accept-Encoding"))
+}
+
+func writeBody(w http.ResponseWriter, status int, enc string, body []byte) {
+	h := w.Header()
+	h.Set("Content-Type", "application/json")
+	if enc == "" {
+		h.Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+		return
+	}
+
+	h.Set("Content-Encoding", enc)
+	w.WriteHeader(status)
+	pool := encoders[enc]
+	zw := pool.Get().(resetWriter)
+	defer pool.Put(zw)
+	zw.Reset(w)
+	_, _ = zw.Write(body)
+	_ = zw.Close()
+}
+
+func (s *Server) cacheControl() string {
+	if s.cfg.HTTP.CacheMaxAge > 0 {
+		return "private, max-age=" + strconv.Itoa(s.cfg.HTTP.CacheMaxAge)
+	}
+	// orders can be re-ingested, so make clients revalidate; a 304 is cheap
+	return "private, no-cache"
+}
+
+// etag is strong, so each content coding gets its own tag, the way Apache
+// does it: "<hash>" for identity, "<hash>-gzip" for gzip.
+func etag(tag, enc string) string {
+	if enc != "" {
+		tag += "-" + enc
+	}
+	return `"` + tag + `"`
+}
+
+// etagMatches applies the weak comparison If-None-Match asks for and treats
+// all codings of one body as the same.
+func etagMatches(header, tag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" {
+			return true
+		}
+		t = strings.Trim(strings.TrimPrefix(t, "W/"), `"`)
+		for enc := range encoders {
+			t = strings.TrimSuffix(t, "-"+enc)
+		}
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateEncoding picks br over gzip among the codings the client
+// accepts with a non-zero q, or "" for identity.
+func negotiateEncoding(header string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			q, _ = strconv.ParseFloat(strings.TrimSpace(v), 64)
+		}
+		accepted[name] = q > 0
+	}
+	for _, enc := range []string{"br", "gzip"} {
+		if ok, listed := accepted[enc]; ok || (!listed && accepted["*"]) {
+			return enc
+		}
+	}
+	return ""
+}
+
+// decodedBody undoes the content coding of a recorded response so it can
+// be checked against the spec.
+func decodedBody(enc string, body []byte) ([]byte, error) {
+	var rd io.Reader
+	switch enc {
+	case "":
+		return body, nil
+	case "gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		rd = zr
+	case "br":
+		rd = brotli.NewReader(bytes.NewReader(body))
+	default:
+		return body, nil
+	}
+	return io.ReadAll(rd)
+}
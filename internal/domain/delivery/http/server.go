@@ -2,86 +2,161 @@ package http
 
 import (
 	"context"
-	"encoding/json"
 	"net/http"
+	"sync/atomic"
 
 	"order-service/config"
+	"order-service/internal/domain/auth"
+	"order-service/internal/domain/delivery/graphql"
+	"order-service/internal/domain/health"
+	"order-service/internal/domain/masking"
+	"order-service/internal/domain/metrics"
+	"order-service/internal/domain/ratelimit"
 	"order-service/internal/domain/usecase"
 
+	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
 
 type Server struct {
-	cfg *config.ConfigModel
-	uc  *usecase.OrderUC
-	log *zap.SugaredLogger
+	cfg  *config.ConfigModel
+	uc   *usecase.OrderUC
+	gql  *graphql.Handler
+	auth *auth.Authenticator
+	mask *masking.Policy
+	log  *zap.SugaredLogger
+
+	limiter *ratelimit.Limiter
+	health  *health.Checker
+
+	wsConns atomic.Int64
 }
 
-func NewServer(cfg *config.ConfigModel, uc *usecase.OrderUC, l *zap.Logger) (*Server, error) {
-	return &Server{cfg: cfg, uc: uc, log: l.Named("http").Sugar()}, nil
+func NewServer(
+	cfg *config.ConfigModel,
+	uc *usecase.OrderUC,
+	gql *graphql.Handler,
+	a *auth.Authenticator,
+	mask *masking.Policy,
+	limiter *ratelimit.Limiter,
+	hc *health.Checker,
+	l *zap.Logger,
+) (*Server, error) {
+	return &Server{cfg: cfg, uc: uc, gql: gql, auth: a, mask: mask, limiter: limiter, health: hc, log: l.Named("http").Sugar()}, nil
 }
 
 func (s *Server) OnStart() error {
+	spec, err := buildSpec(s.cfg)
+	if err != nil {
+		return err
+	}
+	r, err := s.routes(spec)
+	if err != nil {
+		return err
+	}
+	if err := checkSpec(r, spec); err != nil {
+		return err
+	}
+
 	go s.uc.WarmCache(context.Background())
 
+	go func() {
+		s.log.Infow("http listen", "addr", s.cfg.HTTP.Addr)
+		if err := http.ListenAndServe(s.cfg.HTTP.Addr, r); err != nil && err != http.ErrServerClosed {
+			s.log.Errorw("http serve error", "error", err)
+		}
+	}()
+	return nil
+}
+
+// routes builds the router of the API described by spec.
+func (s *Server) routes(spec *openapi3.T) (chi.Router, error) {
+	validate, err := s.validateAPI(spec, s.cfg.HTTP.ValidateResponses)
+	if err != nil {
+		return nil, err
+	}
+
+	// validate reads whole bodies, so it runs only once the caller is
+	// known to be allowed and within its rate
 	r := chi.NewRouter()
+	r.Use(requestID, s.accessLog)
+	r.Use(s.authenticate)
 
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "web/index.html")
 	})
+	r.Get("/docs", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "web/docs.html")
+	})
+	r.Get("/metrics", metrics.Handler().ServeHTTP)
 
-	r.Get("/recent", func(w http.ResponseWriter, r *http.Request) {
-		s.log.Infow("request", "method", "GET", "path", "/recent")
-		ids, err := s.uc.RecentIDs(r.Context(), 20)
-		if err != nil {
-			s.log.Errorw("recent failed", "error", err)
-			http.Error(w, "internal error", http.StatusInternalServerError)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(ids)
+	r.Group(func(r chi.Router) {
+		r.Use(validate)
+
+		r.Get("/openapi.json", s.serveSpec(spec))
+		r.Get("/healthz", s.handleLive)
+		r.Get("/readyz", s.handleReady)
 	})
 
-	r.Get("/order/{uid}", func(w http.ResponseWriter, r *http.Request) {
-		uid := chi.URLParam(r, "uid")
-		s.log.Infow("request", "method", "GET", "path", "/order/{uid}", "order_uid", uid)
+	r.Group(func(r chi.Router) {
+		r.Use(s.require(readRoles...), s.rateLimit, validate)
 
-		if uid == "" {
-			s.log.Warnw("missing uid")
-			http.Error(w, "missing id", http.StatusBadRequest)
-			return
-		}
+		r.Get("/recent", func(w http.ResponseWriter, r *http.Request) {
+			ids, err := s.uc.RecentIDs(r.Context(), 20)
+			if err != nil {
+				s.logger(r).Errorw("recent failed", "error", err)
+				s.writeError(w, r, err)
+				return
+			}
+			s.writeCacheable(w, r, ids)
+		})
 
-		obj, err := s.uc.Get(r.Context(), uid)
-		if err != nil {
-			s.log.Warnw("bad id", "order_uid", uid, "error", err)
-			http.Error(w, "bad id: "+err.Error(), http.StatusBadRequest)
-			return
-		}
-		if obj == nil {
-			s.log.Infow("not found", "order_uid", uid)
-			http.Error(w, "not found", http.StatusNotFound)
-			return
-		}
+		r.Get("/order/{uid}", func(w http.ResponseWriter, r *http.Request) {
+			uid := chi.URLParam(r, "uid")
+			if uid == "" {
+				s.logger(r).Warnw("missing uid")
+				writeStatus(w, r, http.StatusBadRequest, codeInvalidID, "missing id")
+				return
+			}
 
-		b, err := json.MarshalIndent(obj, "", "  ")
-		if err != nil {
-			s.log.Errorw("encode error", "order_uid", uid, "error", err)
-			http.Error(w, "encode error", http.StatusInternalServerError)
-			return
-		}
+			obj, err := s.uc.Get(r.Context(), uid)
+			if err != nil {
+				s.logger(r).Warnw("get failed", "order_uid", uid, "error", err)
+				s.writeError(w, r, err)
+				return
+			}
+
+			s.logger(r).Infow("response ok", "order_uid", uid)
+			s.writeCacheable(w, r, s.mask.Apply(r.Context(), obj))
+		})
 
-		w.Header().Set("Content-Type", "application/json")
-		s.log.Infow("response ok", "order_uid", uid)
-		_, _ = w.Write(b)
+		r.Post("/orders/batch-get", s.handleBatchGet)
+		r.Get("/orders/stream", s.handleStream)
+		r.Get("/order/{uid}/watch", s.handleWatch)
+		r.Post("/graphql", s.gql.ServeHTTP)
 	})
 
-	go func() {
-		s.log.Infow("http listen", "addr", s.cfg.HTTP.Addr)
-		if err := http.ListenAndServe(s.cfg.HTTP.Addr, r); err != nil && err != http.ErrServerClosed {
-			s.log.Errorw("http serve error", "error", err)
-		}
-	}()
-	return nil
+	r.Group(func(r chi.Router) {
+		r.Use(s.require(writeRoles...), s.rateLimit, validate)
+
+		r.Post("/orders", s.handleIngest)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(s.require(exportRoles...), s.rateLimit, validate)
+
+		r.Get("/orders/export", s.handleExport)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(s.require(adminRoles...), s.rateLimit, validate)
+
+		r.Post("/admin/cache/warm", func(w http.ResponseWriter, r *http.Request) {
+			go s.uc.WarmCache(context.Background())
+			w.WriteHeader(http.StatusAccepted)
+		})
+	})
+
+	return r, nil
 }
@@ -0,0 +1,295 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/config"
+	"order-service/internal/domain/auth"
+	"order-service/internal/domain/delivery/graphql"
+	"order-service/internal/domain/entities"
+	"order-service/internal/domain/health"
+	"order-service/internal/domain/masking"
+	"order-service/internal/domain/ratelimit"
+	"order-service/internal/domain/usecase"
+
+	"github.com/google/uuid"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// memRepo stands in for Postgres.
+type memRepo struct {
+	mu     sync.Mutex
+	orders map[uuid.UUID]*entities.Order
+	// exportErr ends Export after every order was handed out
+	exportErr error
+	// fail makes the named methods return the error
+	fail map[string]error
+}
+
+func newMemRepo(orders ...*entities.Order) *memRepo {
+	r := &memRepo{orders: map[uuid.UUID]*entities.Order{}}
+	for _, o := range orders {
+		r.orders[o.OrderId] = o
+	}
+	return r
+}
+
+func (r *memRepo) Find(_ context.Context, id string) (*entities.Order, error) {
+	if err := r.fail["Find"]; err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.orders[uuid.MustParse(id)], nil
+}
+
+func (r *memRepo) Save(_ context.Context, o *entities.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.orders[o.OrderId] = o
+	return nil
+}
+
+func (r *memRepo) SaveBatch(ctx context.Context, orders []*entities.Order) error {
+	for _, o := range orders {
+		_ = r.Save(ctx, o)
+	}
+	return nil
+}
+
+func (r *memRepo) Unchanged(context.Context, []*entities.Order) (map[uuid.UUID]bool, error) {
+	return nil, nil
+}
+
+func (r *memRepo) CacheRestore(context.Context) ([]*entities.Order, error) { return r.all(), nil }
+
+func (r *memRepo) RecentIDs(_ context.Context, limit int) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	for _, o := range r.all() {
+		ids = append(ids, o.OrderId)
+	}
+	return ids[:min(limit, len(ids))], nil
+}
+
+func (r *memRepo) ListIDs(_ context.Context, f entities.OrderFilter) ([]uuid.UUID, error) {
+	return r.RecentIDs(context.Background(), f.Limit)
+}
+
+func (r *memRepo) FindMany(ctx context.Context, ids []uuid.UUID) ([]*entities.Order, error) {
+	var out []*entities.Order
+	for _, id := range ids {
+		if o, _ := r.Find(ctx, id.String()); o != nil {
+			out = append(out, o)
+		}
+	}
+	return out, nil
+}
+
+func (r *memRepo) OrdersByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*entities.Order, error) {
+	list, _ := r.FindMany(ctx, ids)
+	out := map[uuid.UUID]*entities.Order{}
+	for _, o := range list {
+		out[o.OrderId] = o
+	}
+	return out, nil
+}
+
+func (r *memRepo) DeliveriesByOrders(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*entities.Delivery, error) {
+	if err := r.fail["DeliveriesByOrders"]; err != nil {
+		return nil, err
+	}
+	list, _ := r.FindMany(ctx, ids)
+	out := map[uuid.UUID]*entities.Delivery{}
+	for _, o := range list {
+		out[o.OrderId] = &o.Delivery
+	}
+	return out, nil
+}
+
+func (r *memRepo) PaymentsByOrders(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*entities.Payment, error) {
+	list, _ := r.FindMany(ctx, ids)
+	out := map[uuid.UUID]*entities.Payment{}
+	for _, o := range list {
+		out[o.OrderId] = &o.Payment
+	}
+	return out, nil
+}
+
+func (r *memRepo) ItemsByOrders(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]entities.Item, error) {
+	list, _ := r.FindMany(ctx, ids)
+	out := map[uuid.UUID][]entities.Item{}
+	for _, o := range list {
+		out[o.OrderId] = o.Items
+	}
+	return out, nil
+}
+
+func (r *memRepo) Export(_ context.Context, _ entities.OrderFilter, fn func(*entities.Order) error) error {
+	for _, o := range r.all() {
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+	return r.exportErr
+}
+
+// all returns the orders newest first.
+func (r *memRepo) all() []*entities.Order {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]*entities.Order, 0, len(r.orders))
+	for _, o := range r.orders {
+		out = append(out, o)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].DateCreated.After(out[j].DateCreated) })
+	return out
+}
+
+// memCache stands in for Redis.
+type memCache struct {
+	mu sync.Mutex
+	m  map[string]*entities.Order
+}
+
+func (c *memCache) Get(id string) (*entities.Order, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	o, ok := c.m[id]
+	return o, ok
+}
+
+func (c *memCache) Set(id string, o *entities.Order) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.m[id] = o
+}
+
+func (c *memCache) GetMany(ids []string) map[string]*entities.Order {
+	out := map[string]*entities.Order{}
+	for _, id := range ids {
+		if o, ok := c.Get(id); ok {
+			out[id] = o
+		}
+	}
+	return out
+}
+
+func (c *memCache) SetMany(orders []*entities.Order) {
+	for _, o := range orders {
+		c.Set(o.OrderId.String(), o)
+	}
+}
+
+func (c *memCache) Delete(_ context.Context, ids []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range ids {
+		delete(c.m, id)
+	}
+	return nil
+}
+
+func testConfig() *config.ConfigModel {
+	cfg := &config.ConfigModel{}
+	cfg.HTTP.BatchGetMax = 500
+	cfg.Masking.DefaultRole = auth.RoleAdmin
+	cfg.Masking.Rules = map[string]string{auth.RoleAdmin: "*:none", auth.RoleReader: "*:redact"}
+	cfg.Health.Critical = []string{"postgres"}
+	cfg.Health.TimeoutMs = 1000
+	cfg.Stream.BufferSize = 10
+	return cfg
+}
+
+func testOrder() *entities.Order {
+	return &entities.Order{
+		OrderId:         uuid.MustParse("b563feb7-b2b8-4b6c-9f5d-000000000001"),
+		TrackNumber:     "WBILMTESTTRACK",
+		Entry:           "WBIL",
+		Locale:          "en",
+		CustomerId:      "test",
+		DeliveryService: "meest",
+		ShardKey:        9,
+		SmId:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		Delivery: entities.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: entities.Payment{
+			TransactionId: "b563feb7b2b84b6ctest", Currency: "USD", Provider: "wbpay", Amount: 1817,
+			PaymentDt: 1637907727, Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317,
+		},
+		Items: []entities.Item{{
+			ChrtId: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, RID: "ab4219087a764ae0btest",
+			Name: "Mascaras", Sale: 30, Size: "0", TotalPrice: 317, NmID: 2389212, Brand: "Vivienne Sabo", Status: 202,
+		}},
+	}
+}
+
+// newTestServer builds the real router over an in-memory use case.
+func newTestServer(t *testing.T, cfg *config.ConfigModel, orders ...*entities.Order) http.Handler {
+	t.Helper()
+	s := newServerOver(t, cfg, newMemRepo(orders...))
+	spec, err := buildSpec(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := s.routes(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func newServerOver(t *testing.T, cfg *config.ConfigModel, repo *memRepo) *Server {
+	t.Helper()
+	l := zap.NewNop()
+
+	uc, err := usecase.NewOrderUC(cfg, repo, &memCache{m: map[string]*entities.Order{}}, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mask, err := masking.NewPolicy(cfg, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gql, err := graphql.NewHandler(uc, mask, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := auth.NewAuthenticator(cfg, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter, err := ratelimit.NewLimiter(cfg, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hc *health.Checker
+	app := fx.New(
+		fx.Supply(cfg, l),
+		fx.Provide(
+			health.NewChecker,
+			health.AsCheck(func() health.Check {
+				return health.Check{Name: "postgres", Probe: func(context.Context) error { return nil }}
+			}),
+		),
+		fx.Populate(&hc),
+		fx.NopLogger,
+	)
+	if err := app.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewServer(cfg, uc, gql, a, mask, limiter, hc, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
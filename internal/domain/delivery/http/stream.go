@@ -0,0 +1,115 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"order-service/internal/domain/entities"
+)
+
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeStatus(w, r, http.StatusInternalServerError, codeInternal, "streaming unsupported")
+		return
+	}
+
+	q := r.URL.Query()
+	customer := q.Get("customer")
+	service := q.Get("delivery_service")
+	var minAmount int64
+	if v := q.Get("min_amount"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeStatus(w, r, http.StatusBadRequest, codeBadRequest, "bad min_amount")
+			return
+		}
+		minAmount = n
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = q.Get("last_event_id")
+	}
+	var after uint64
+	if lastID != "" {
+		n, err := strconv.ParseUint(lastID, 10, 64)
+		if err != nil {
+			writeStatus(w, r, http.StatusBadRequest, codeBadRequest, "bad Last-Event-ID")
+			return
+		}
+		after = n
+	}
+
+	filter := func(o *entities.Order) bool {
+		if customer != "" && o.CustomerId != customer {
+			return false
+		}
+		if service != "" && o.DeliveryService != service {
+			return false
+		}
+		return o.Payment.Amount >= minAmount
+	}
+
+	sub, backlog := s.uc.Subscribe(after, filter)
+	defer sub.Close()
+
+	s.logger(r).Infow("stream open", "customer", customer, "delivery_service", service,
+		"min_amount", minAmount, "last_event_id", after, "backlog", len(backlog))
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for _, ev := range backlog {
+		if err := writeEvent(w, ev.ID, s.mask.Apply(r.Context(), ev.Order)); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	interval := time.Duration(s.cfg.Stream.HeartbeatSeconds) * time.Second
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	heartbeat := time.NewTicker(interval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			s.logger(r).Debugw("stream closed by client")
+			return
+		case ev, ok := <-sub.C:
+			if !ok {
+				s.logger(r).Infow("stream dropped, subscriber too slow")
+				return
+			}
+			if err := writeEvent(w, ev.ID, s.mask.Apply(r.Context(), ev.Order)); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, id uint64, o *entities.Order) error {
+	b, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: order\ndata: %s\n\n", id, b)
+	return err
+}
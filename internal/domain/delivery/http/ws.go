@@ -0,0 +1,123 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"order-service/internal/domain/entities"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const wsReadLimit = 512
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+}
+
+func (s *Server) handleWatch(w http.ResponseWriter, r *http.Request) {
+	uid := chi.URLParam(r, "uid")
+	u, err := uuid.Parse(uid)
+	if err != nil {
+		s.writeError(w, r, fmt.Errorf("%w: %v", entities.ErrInvalidID, err))
+		return
+	}
+
+	max := int64(s.cfg.WebSocket.MaxConnections)
+	if n := s.wsConns.Add(1); max > 0 && n > max {
+		s.wsConns.Add(-1)
+		s.logger(r).Warnw("ws connection limit reached", "order_uid", uid, "limit", max)
+		writeStatus(w, r, http.StatusServiceUnavailable, codeUnavailable, "too many connections")
+		return
+	}
+	defer s.wsConns.Add(-1)
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.logger(r).Warnw("ws upgrade failed", "order_uid", uid, "error", err)
+		return
+	}
+	defer conn.Close()
+
+	// subscribe before loading the current state so an update saved in
+	// between is not lost
+	sub, _ := s.uc.Subscribe(0, func(o *entities.Order) bool { return o.OrderId == u })
+	defer sub.Close()
+	s.logger(r).Infow("ws watch open", "order_uid", uid, "connections", s.wsConns.Load())
+
+	writeTimeout := time.Duration(s.cfg.WebSocket.WriteTimeoutSeconds) * time.Second
+	if writeTimeout <= 0 {
+		writeTimeout = 10 * time.Second
+	}
+	pingEvery := time.Duration(s.cfg.WebSocket.PingSeconds) * time.Second
+	if pingEvery <= 0 {
+		pingEvery = 30 * time.Second
+	}
+
+	// the client only sends control frames; reading is needed to notice
+	// the close and to process pongs
+	closed := make(chan struct{})
+	conn.SetReadLimit(wsReadLimit)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * pingEvery))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * pingEvery))
+	})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(o *entities.Order) bool {
+		_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if err := conn.WriteJSON(s.mask.Apply(r.Context(), o)); err != nil {
+			s.logger(r).Debugw("ws write failed", "order_uid", uid, "error", err)
+			return false
+		}
+		return true
+	}
+
+	cur, err := s.uc.Get(r.Context(), uid)
+	switch {
+	case errors.Is(err, entities.ErrNotFound):
+		// not ingested yet: wait for it
+	case err != nil:
+		s.logger(r).Warnw("ws initial load failed", "order_uid", uid, "error", err)
+	case !send(cur):
+		return
+	}
+
+	ping := time.NewTicker(pingEvery)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-closed:
+			s.logger(r).Infow("ws watch closed", "order_uid", uid)
+			return
+		case ev, ok := <-sub.C:
+			if !ok {
+				s.logger(r).Infow("ws watch dropped, client too slow", "order_uid", uid)
+				_ = conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"),
+					time.Now().Add(writeTimeout))
+				return
+			}
+			if !send(ev.Order) {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package kafka
+
+import (
+	"context"
+	"time"
+)
+
+// backoff is a wait doubling from 500ms up to 5s; the zero value is ready.
+type backoff struct {
+	d time.Duration
+}
+
+func (b *backoff) next() time.Duration {
+	switch {
+	case b.d == 0:
+		b.d = 500 * time.Millisecond
+	case b.d < 5*time.Second:
+		b.d = min(2*b.d, 5*time.Second)
+	}
+	return b.d
+}
+
+func (b *backoff) reset() { b.d = 0 }
+
+// sleep waits for d, reporting false if ctx ends first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -2,14 +2,13 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
-	"io"
-	"net"
+	"fmt"
+	"os"
 	"time"
 
 	"order-service/config"
 	"order-service/internal/domain/entities"
+	"order-service/internal/domain/schemaregistry"
 	"order-service/internal/domain/usecase"
 
 	"github.com/segmentio/kafka-go"
@@ -18,73 +17,105 @@ import (
 
 type Consumer struct {
 	cfg *config.ConfigModel
-	uc  *usecase.OrderUC
+	uc  saver
 	log *zap.SugaredLogger
-}
 
-func NewConsumer(cfg *config.ConfigModel, uc *usecase.OrderUC, l *zap.Logger) (*Consumer, error) {
-	return &Consumer{cfg: cfg, uc: uc, log: l.Named("kafka.consumer").Sugar()}, nil
+	// unique per process so the group check can find this member
+	clientID string
+	dialer   *kafka.Dialer
+	// TLS and SASL for kafka.Client and writers, as dialer has them
+	transport *kafka.Transport
+	subs      []*subscription
+	// nil without KAFKA_DLQ_TOPIC
+	dlq messageWriter
 }
 
-func (c *Consumer) OnStart() error {
-	r := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:               c.cfg.Kafka.Brokers,
-		GroupID:               c.cfg.Kafka.GroupID,
-		Topic:                 c.cfg.Kafka.Topic,
-		StartOffset:           kafka.LastOffset,
-		CommitInterval:        0,
-		MinBytes:              1_000,
-		MaxBytes:              1_000_000,
-		MaxWait:               500 * time.Millisecond,
-		WatchPartitionChanges: true,
-		ReadBackoffMin:        500 * time.Millisecond,
-		ReadBackoffMax:        5 * time.Second,
-	})
+// saver is the part of the use case messages are saved through.
+type saver interface {
+	Set(ctx context.Context, o *entities.Order) error
+	SetBatch(ctx context.Context, orders []*entities.Order) (invalid []*entities.Order, err error)
+}
 
-	_ = waitTopicReady(context.Background(), c.cfg.Kafka.Brokers, c.cfg.Kafka.Topic, 30*time.Second, c.log)
+// messageWriter writes dead letters; a *kafka.Writer outside of tests.
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
 
-	go func() {
-		defer r.Close()
-		c.log.Infow("listening", "brokers", c.cfg.Kafka.Brokers, "topic", c.cfg.Kafka.Topic, "group", c.cfg.Kafka.GroupID)
+func NewConsumer(cfg *config.ConfigModel, uc *usecase.OrderUC, l *zap.Logger) (*Consumer, error) {
+	host, _ := os.Hostname()
+	c := &Consumer{
+		cfg:      cfg,
+		uc:       uc,
+		log:      l.Named("kafka.consumer").Sugar(),
+		clientID: fmt.Sprintf("order-service-%s-%d", host, os.Getpid()),
+	}
+	var err error
+	if c.dialer, err = newDialer(cfg, c.clientID); err != nil {
+		return nil, err
+	}
+	if c.transport, err = newTransport(cfg, c.clientID); err != nil {
+		return nil, err
+	}
 
-		ctx := context.Background()
-		backoff := 500 * time.Millisecond
+	reg, err := schemaregistry.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("kafka: %w", err)
+	}
+	if cfg.Kafka.DLQTopic != "" {
+		c.dlq = &kafka.Writer{
+			Addr:                   kafka.TCP(cfg.Kafka.Brokers...),
+			Transport:              c.transport,
+			Topic:                  cfg.Kafka.DLQTopic,
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireAll,
+			AllowAutoTopicCreation: true,
+		}
+	}
 
-		for {
-			msg, err := r.ReadMessage(ctx)
-			if err != nil {
-				if errors.Is(err, context.Canceled) ||
-					errors.Is(err, context.DeadlineExceeded) ||
-					errors.Is(err, io.EOF) ||
-					errors.Is(err, net.ErrClosed) {
-					return
-				}
-				c.log.Warnw("read error, will retry", "error", err)
-				time.Sleep(backoff)
-				if backoff < 5*time.Second {
-					backoff *= 2
-				}
-				continue
+	built := map[string]Decoder{}
+	seen := map[string]string{}
+	for _, sc := range cfg.Kafka.Subscriptions {
+		// two subscriptions in one group would take each other's partitions
+		for _, k := range []string{"topic " + sc.Topic, "group " + sc.Group} {
+			if other, dup := seen[k]; dup {
+				return nil, fmt.Errorf("kafka: topics %q and %q share %s", other, sc.Topic, k)
 			}
-			backoff = 500 * time.Millisecond
-
-			var ord entities.Order
-			if err := json.Unmarshal(msg.Value, &ord); err != nil {
-				c.log.Warnw("bad json, skip", "partition", msg.Partition, "offset", msg.Offset, "error", err)
-				_ = r.CommitMessages(ctx, msg)
-				continue
+			seen[k] = sc.Topic
+		}
+		dec, ok := built[sc.Decoder]
+		if !ok {
+			if dec, err = newDecoder(sc.Decoder, decoderEnv{cfg: cfg, reg: reg, log: c.log}); err != nil {
+				return nil, fmt.Errorf("kafka: topic %q: %w", sc.Topic, err)
 			}
+			built[sc.Decoder] = dec
+		}
+		c.subs = append(c.subs, newSubscription(c, sc, dec))
+	}
+	return c, nil
+}
 
-			c.log.Infow("received", "order_uid", ord.OrderId.String(), "key", string(msg.Key), "partition", msg.Partition, "offset", msg.Offset)
+// OnStart joins the consumer group of every subscription.
+func (c *Consumer) OnStart() error {
+	for _, s := range c.subs {
+		if err := s.start(); err != nil {
+			return fmt.Errorf("kafka: topic %q: %w", s.topic, err)
+		}
+	}
+	go c.monitorLag(time.Duration(c.cfg.Kafka.LagIntervalSeconds) * time.Second)
+	return nil
+}
 
-			if err := c.uc.Set(ctx, &ord); err != nil {
-				c.log.Errorw("save failed", "order_uid", ord.OrderId.String(), "error", err)
-				continue
-			}
-			if err := r.CommitMessages(ctx, msg); err != nil {
-				c.log.Errorw("commit failed", "partition", msg.Partition, "offset", msg.Offset, "error", err)
-			}
+// client talks to the brokers outside of any group.
+func (c *Consumer) client() *kafka.Client {
+	return &kafka.Client{Addr: kafka.TCP(c.cfg.Kafka.Brokers...), Transport: c.transport}
+}
+
+// subscriptionFor returns the subscription of topic, nil if there is none.
+func (c *Consumer) subscriptionFor(topic string) *subscription {
+	for _, s := range c.subs {
+		if s.topic == topic {
+			return s
 		}
-	}()
+	}
 	return nil
 }
@@ -0,0 +1,132 @@
+package kafka
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"order-service/config"
+	"order-service/internal/domain/entities"
+	"order-service/internal/domain/metrics"
+	"order-service/internal/domain/schemaregistry"
+
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+)
+
+// Decoder maps a message of some topic to an order.
+type Decoder interface {
+	Decode(msg kafka.Message) (*entities.Order, error)
+}
+
+type DecoderFunc func(msg kafka.Message) (*entities.Order, error)
+
+func (f DecoderFunc) Decode(msg kafka.Message) (*entities.Order, error) { return f(msg) }
+
+// decoderEnv is what decoders are built from. reg is nil when
+// SCHEMA_REGISTRY_URL is not set.
+type decoderEnv struct {
+	cfg *config.ConfigModel
+	reg schemaregistry.Registry
+	log *zap.SugaredLogger
+}
+
+// decoders holds the constructors subscriptions pick from by name.
+var decoders = map[string]func(env decoderEnv) (Decoder, error){
+	"json": func(env decoderEnv) (Decoder, error) {
+		return newJSONDecoder(env)
+	},
+	"envelope": func(env decoderEnv) (Decoder, error) {
+		d, err := newJSONDecoder(env)
+		if err != nil {
+			return nil, err
+		}
+		return DecoderFunc(d.decodeEnvelope), nil
+	},
+	"avro": func(env decoderEnv) (Decoder, error) {
+		return newAvroDecoder(env.reg)
+	},
+	"protobuf": func(env decoderEnv) (Decoder, error) {
+		return newProtobufDecoder(env.reg)
+	},
+	"auto": func(env decoderEnv) (Decoder, error) {
+		return newAutoDecoder(env)
+	},
+}
+
+// newDecoder builds the decoder registered as name.
+func newDecoder(name string, env decoderEnv) (Decoder, error) {
+	mk, ok := decoders[name]
+	if !ok {
+		names := make([]string, 0, len(decoders))
+		for n := range decoders {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown decoder %q, have %v", name, names)
+	}
+	return mk(env)
+}
+
+// jsonDecoder reads the order as the service itself serves it. Plain
+// json.Unmarshal drops unknown keys and zero-fills missing ones, so a
+// misspelt order_uid becomes the nil UUID; KAFKA_JSON_STRICT checks the
+// document first, logging its problems in warn mode and refusing it in
+// strict mode.
+type jsonDecoder struct {
+	mode string
+	log  *zap.SugaredLogger
+}
+
+func newJSONDecoder(env decoderEnv) (*jsonDecoder, error) {
+	switch mode := env.cfg.Kafka.JSONStrict; mode {
+	case strictOff, strictWarn, strictOn:
+		return &jsonDecoder{mode: mode, log: env.log}, nil
+	default:
+		return nil, fmt.Errorf("unknown KAFKA_JSON_STRICT %q, have off, warn, strict", mode)
+	}
+}
+
+func (d *jsonDecoder) Decode(msg kafka.Message) (*entities.Order, error) {
+	return d.decode(msg, msg.Value)
+}
+
+func (d *jsonDecoder) decode(msg kafka.Message, body []byte) (*entities.Order, error) {
+	if d.mode != strictOff {
+		if probs := checkOrderJSON(body); len(probs) > 0 {
+			metrics.KafkaJSONViolations.WithLabelValues(msg.Topic, d.mode).Inc()
+			if d.mode == strictOn {
+				return nil, fmt.Errorf("strict json: %s", strings.Join(probs, "; "))
+			}
+			d.log.Warnw("order json does not match the contract", "topic", msg.Topic,
+				"partition", msg.Partition, "offset", msg.Offset, "problems", probs)
+		}
+	}
+	var ord entities.Order
+	if err := json.Unmarshal(body, &ord); err != nil {
+		return nil, fmt.Errorf("bad json: %w", err)
+	}
+	return &ord, nil
+}
+
+// decodeEnvelope reads event feeds that wrap the order, as in
+// {"event": "order_created", "data": {...}}; "payload" and "order" are
+// accepted for "data" too. Strict mode applies to the order only.
+func (d *jsonDecoder) decodeEnvelope(msg kafka.Message) (*entities.Order, error) {
+	var env struct {
+		Data    json.RawMessage `json:"data"`
+		Payload json.RawMessage `json:"payload"`
+		Order   json.RawMessage `json:"order"`
+	}
+	if err := json.Unmarshal(msg.Value, &env); err != nil {
+		return nil, fmt.Errorf("bad json: %w", err)
+	}
+	for _, body := range []json.RawMessage{env.Data, env.Payload, env.Order} {
+		if len(body) > 0 && string(body) != "null" {
+			return d.decode(msg, body)
+		}
+	}
+	return nil, errors.New("envelope has no data, payload or order")
+}
@@ -0,0 +1,293 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	orderv1 "order-service/api/order/v1"
+	"order-service/internal/domain/entities"
+	"order-service/internal/domain/schemaregistry"
+
+	"github.com/hamba/avro/v2"
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Content types of the formats, set by the producer in the content-type
+// header and read by the auto decoder.
+const (
+	contentTypeJSON     = "application/json"
+	contentTypeAvro     = "application/vnd.apache.avro+binary"
+	contentTypeProtobuf = "application/x-protobuf"
+)
+
+const registryTimeout = 10 * time.Second
+
+var errNoRegistry = errors.New("SCHEMA_REGISTRY_URL is not set")
+
+// unavailableError is a failure to reach the schema registry. It says
+// nothing about the message, which is tried again instead of skipped.
+type unavailableError struct{ err error }
+
+func (e *unavailableError) Error() string { return "schema registry unavailable: " + e.err.Error() }
+func (e *unavailableError) Unwrap() error { return e.err }
+
+// retryable tells decode errors worth trying again from broken messages.
+func retryable(err error) bool {
+	var u *unavailableError
+	return errors.As(err, &u)
+}
+
+// lookup fetches schema id. An id the registry does not know is the
+// message's fault; any other failure is the registry's.
+func lookup(reg schemaregistry.Registry, id int) (schemaregistry.Schema, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), registryTimeout)
+	defer cancel()
+	s, err := reg.ByID(ctx, id)
+	if err != nil && !errors.Is(err, schemaregistry.ErrNotFound) {
+		return s, &unavailableError{err: err}
+	}
+	return s, err
+}
+
+// avroDecoder reads orders written with any registered schema that
+// resolves against order.avsc: added fields with defaults are fine, a
+// renamed or retyped field is an error rather than a zero value.
+type avroDecoder struct {
+	reg    schemaregistry.Registry
+	reader avro.Schema
+
+	mu       sync.Mutex
+	resolved map[int]avro.Schema
+}
+
+func newAvroDecoder(reg schemaregistry.Registry) (*avroDecoder, error) {
+	if reg == nil {
+		return nil, errNoRegistry
+	}
+	reader, err := avro.Parse(orderv1.AvroSchema)
+	if err != nil {
+		return nil, fmt.Errorf("order.avsc: %w", err)
+	}
+	return &avroDecoder{reg: reg, reader: reader, resolved: map[int]avro.Schema{}}, nil
+}
+
+func (d *avroDecoder) Decode(msg kafka.Message) (*entities.Order, error) {
+	id, payload, err := unframe(msg.Value)
+	if err != nil {
+		return nil, err
+	}
+	sc, err := d.schema(id)
+	if err != nil {
+		return nil, err
+	}
+	var a orderv1.AvroOrder
+	if err := avro.Unmarshal(sc, payload, &a); err != nil {
+		return nil, fmt.Errorf("bad avro for schema %d: %w", id, err)
+	}
+	return a.ToEntity()
+}
+
+// schema returns writer schema id resolved against ours. Only successful
+// resolutions are cached.
+func (d *avroDecoder) schema(id int) (avro.Schema, error) {
+	d.mu.Lock()
+	sc, ok := d.resolved[id]
+	d.mu.Unlock()
+	if ok {
+		return sc, nil
+	}
+
+	s, err := lookup(d.reg, id)
+	if err != nil {
+		return nil, err
+	}
+	if s.Type != schemaregistry.TypeAvro {
+		return nil, fmt.Errorf("schema %d is %s, not AVRO", id, s.Type)
+	}
+	// a cache of its own: writer schemas reuse our names with other fields
+	writer, err := avro.ParseWithCache(s.Schema, "", &avro.SchemaCache{})
+	if err != nil {
+		return nil, fmt.Errorf("schema %d: %w", id, err)
+	}
+	if sc, err = avro.NewSchemaCompatibility().Resolve(d.reader, writer); err != nil {
+		return nil, fmt.Errorf("schema %d is incompatible with order.avsc: %w", id, err)
+	}
+
+	d.mu.Lock()
+	d.resolved[id] = sc
+	d.mu.Unlock()
+	return sc, nil
+}
+
+// protobufDecoder reads order.v1.Order. Protobuf has no field names on
+// the wire, so a field the writer knows and we do not shows up as unknown
+// data; such messages are refused instead of losing the field.
+type protobufDecoder struct {
+	reg schemaregistry.Registry
+}
+
+func newProtobufDecoder(reg schemaregistry.Registry) (*protobufDecoder, error) {
+	if reg == nil {
+		return nil, errNoRegistry
+	}
+	return &protobufDecoder{reg: reg}, nil
+}
+
+func (d *protobufDecoder) Decode(msg kafka.Message) (*entities.Order, error) {
+	id, rest, err := unframe(msg.Value)
+	if err != nil {
+		return nil, err
+	}
+	idx, payload, err := messageIndexes(rest)
+	if err != nil {
+		return nil, err
+	}
+	if len(idx) != 1 || idx[0] != 0 {
+		return nil, fmt.Errorf("message type %v of schema %d is not order.v1.Order", idx, id)
+	}
+
+	s, err := lookup(d.reg, id)
+	if err != nil {
+		return nil, err
+	}
+	if s.Type != schemaregistry.TypeProtobuf {
+		return nil, fmt.Errorf("schema %d is %s, not PROTOBUF", id, s.Type)
+	}
+
+	var m orderv1.Order
+	if err := proto.Unmarshal(payload, &m); err != nil {
+		return nil, fmt.Errorf("bad protobuf for schema %d: %w", id, err)
+	}
+	if path := unknownFields(m.ProtoReflect(), "order"); path != "" {
+		return nil, fmt.Errorf("schema %d is incompatible with order.proto: unknown fields in %s", id, path)
+	}
+	return orderv1.ToEntity(&m)
+}
+
+// unknownFields returns the path of the first message in m carrying
+// fields its descriptor does not know, "" if there is none.
+func unknownFields(m protoreflect.Message, path string) string {
+	if len(m.GetUnknown()) > 0 {
+		return path
+	}
+	found := ""
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.Message() == nil {
+			return true
+		}
+		name := path + "." + string(fd.Name())
+		if fd.IsList() {
+			for i := 0; i < v.List().Len() && found == ""; i++ {
+				found = unknownFields(v.List().Get(i).Message(), fmt.Sprintf("%s[%d]", name, i))
+			}
+		} else {
+			found = unknownFields(v.Message(), name)
+		}
+		return found == ""
+	})
+	return found
+}
+
+// autoDecoder picks the format by the content-type header, JSON when
+// there is none. Without a registry Avro and protobuf messages fail.
+type autoDecoder struct {
+	json, avro, protobuf Decoder
+}
+
+func newAutoDecoder(env decoderEnv) (*autoDecoder, error) {
+	j, err := newJSONDecoder(env)
+	if err != nil {
+		return nil, err
+	}
+	d := &autoDecoder{json: j, avro: failing(errNoRegistry), protobuf: failing(errNoRegistry)}
+	if env.reg != nil {
+		if d.avro, err = newAvroDecoder(env.reg); err != nil {
+			return nil, err
+		}
+		d.protobuf = &protobufDecoder{reg: env.reg}
+	}
+	return d, nil
+}
+
+func (d *autoDecoder) Decode(msg kafka.Message) (*entities.Order, error) {
+	ct := header(msg, "content-type")
+	switch {
+	case ct == "", strings.HasPrefix(ct, contentTypeJSON):
+		return d.json.Decode(msg)
+	case strings.Contains(ct, "avro"):
+		return d.avro.Decode(msg)
+	case strings.Contains(ct, "protobuf"):
+		return d.protobuf.Decode(msg)
+	}
+	return nil, fmt.Errorf("unsupported content-type %q", ct)
+}
+
+func failing(err error) Decoder {
+	return DecoderFunc(func(kafka.Message) (*entities.Order, error) { return nil, err })
+}
+
+func header(msg kafka.Message, key string) string {
+	for _, h := range msg.Headers {
+		if strings.EqualFold(h.Key, key) {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// encoder frames orders for the producer in one of the formats.
+type encoder struct {
+	contentType string
+	encode      func(o *entities.Order) ([]byte, error)
+}
+
+// newEncoder registers the format's schema under topic-value.
+func newEncoder(ctx context.Context, format, topic string, reg schemaregistry.Registry) (*encoder, error) {
+	if format == "json" || format == "" {
+		return &encoder{contentType: contentTypeJSON, encode: func(o *entities.Order) ([]byte, error) {
+			return json.Marshal(o)
+		}}, nil
+	}
+	if reg == nil {
+		return nil, errNoRegistry
+	}
+	subject := topic + "-value"
+	switch format {
+	case "avro":
+		sc, err := avro.Parse(orderv1.AvroSchema)
+		if err != nil {
+			return nil, err
+		}
+		id, err := reg.Register(ctx, subject, schemaregistry.Schema{Type: schemaregistry.TypeAvro, Schema: orderv1.AvroSchema})
+		if err != nil {
+			return nil, err
+		}
+		return &encoder{contentType: contentTypeAvro, encode: func(o *entities.Order) ([]byte, error) {
+			b, err := avro.Marshal(sc, orderv1.AvroFromEntity(o))
+			if err != nil {
+				return nil, err
+			}
+			return frame(id, nil, b), nil
+		}}, nil
+	case "protobuf":
+		id, err := reg.Register(ctx, subject, schemaregistry.Schema{Type: schemaregistry.TypeProtobuf, Schema: orderv1.ProtoSchema})
+		if err != nil {
+			return nil, err
+		}
+		return &encoder{contentType: contentTypeProtobuf, encode: func(o *entities.Order) ([]byte, error) {
+			b, err := proto.Marshal(orderv1.FromEntity(o))
+			if err != nil {
+				return nil, err
+			}
+			return frame(id, []int{0}, b), nil
+		}}, nil
+	}
+	return nil, fmt.Errorf("unknown producer format %q, have json, avro, protobuf", format)
+}
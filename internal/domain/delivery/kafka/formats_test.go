@@ -0,0 +1,139 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	orderv1 "order-service/api/order/v1"
+	"order-service/internal/domain/schemaregistry"
+
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []string{"json", "avro", "protobuf"} {
+		t.Run(format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "schemas.json")
+			reg, err := schemaregistry.OpenFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			enc, err := newEncoder(context.Background(), format, testTopic, reg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := testOrder()
+			b, err := enc.encode(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := kafka.Message{Topic: testTopic, Value: b,
+				Headers: []kafka.Header{{Key: "content-type", Value: []byte(enc.contentType)}}}
+
+			// the consumer reads the schemas the producer registered
+			reopened, err := schemaregistry.OpenFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := []string{format, "auto"}
+			for _, name := range names {
+				dec, err := newDecoder(name, decoderEnv{cfg: testConfig(), reg: reopened, log: zap.NewNop().Sugar()})
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := dec.Decode(msg)
+				if err != nil {
+					t.Fatalf("%s decoder: %v", name, err)
+				}
+				gj, _ := json.Marshal(got)
+				wj, _ := json.Marshal(want)
+				if string(gj) != string(wj) {
+					t.Errorf("%s decoder:\n got %s\nwant %s", name, gj, wj)
+				}
+			}
+		})
+	}
+}
+
+func TestProducerReusesSchemaID(t *testing.T) {
+	reg := openRegistry(t)
+	var ids []int
+	for range 2 {
+		enc, err := newEncoder(context.Background(), "avro", testTopic, reg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := enc.encode(testOrder())
+		id, _, _ := unframe(b)
+		ids = append(ids, id)
+	}
+	if ids[0] != ids[1] {
+		t.Errorf("same schema registered as %v", ids)
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	ctx := context.Background()
+	reg := openRegistry(t)
+	avroID, err := reg.Register(ctx, testTopic+"-value", schemaregistry.Schema{Type: schemaregistry.TypeAvro, Schema: orderv1.AvroSchema})
+	if err != nil {
+		t.Fatal(err)
+	}
+	protoID, err := reg.Register(ctx, testTopic+"-value", schemaregistry.Schema{Type: schemaregistry.TypeProtobuf, Schema: orderv1.ProtoSchema})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// order_uid renamed: resolving it against order.avsc must fail
+	renamed := strings.Replace(orderv1.AvroSchema, `"order_uid"`, `"order_id"`, 1)
+	renamedID, err := reg.Register(ctx, testTopic+"-value", schemaregistry.Schema{Type: schemaregistry.TypeAvro, Schema: renamed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pb, err := proto.Marshal(orderv1.FromEntity(testOrder()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a field a newer writer added
+	extended := protowire.AppendVarint(protowire.AppendTag(append([]byte(nil), pb...), 99, protowire.VarintType), 1)
+
+	tests := []struct {
+		name, decoder string
+		value         []byte
+		want          string
+	}{
+		{"avro unknown schema id", "avro", frame(99, nil, []byte{0}), "schema 99"},
+		{"avro incompatible schema", "avro", frame(renamedID, nil, []byte{0}), "incompatible with order.avsc"},
+		{"avro id of a protobuf schema", "avro", frame(protoID, nil, []byte{0}), "not AVRO"},
+		{"avro not framed", "avro", []byte(`{"order_uid":"x"}`), "wire format"},
+		{"avro truncated", "avro", frame(avroID, nil, []byte{2}), "bad avro"},
+		{"protobuf unknown schema id", "protobuf", frame(99, []int{0}, pb), "schema 99"},
+		{"protobuf unknown fields", "protobuf", frame(protoID, []int{0}, extended), "incompatible with order.proto"},
+		{"protobuf id of an avro schema", "protobuf", frame(avroID, []int{0}, pb), "not PROTOBUF"},
+		{"protobuf other message type", "protobuf", frame(protoID, []int{1}, pb), "not order.v1.Order"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec, err := newDecoder(tt.decoder, decoderEnv{cfg: testConfig(), reg: reg, log: zap.NewNop().Sugar()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = dec.Decode(kafka.Message{Topic: testTopic, Value: tt.value})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+			if retryable(err) {
+				t.Errorf("%v is retryable: the message would be retried forever", err)
+			}
+			if errors.Is(err, schemaregistry.ErrNotFound) != strings.Contains(tt.name, "unknown schema id") {
+				t.Errorf("ErrNotFound = %v", errors.Is(err, schemaregistry.ErrNotFound))
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"order-service/internal/domain/health"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// brokerCheck passes when at least one broker accepts a connection.
+func (c *Consumer) brokerCheck() health.Check {
+	return health.Check{Name: "kafka", Probe: func(ctx context.Context) error {
+		var errs []error
+		for _, b := range c.cfg.Kafka.Brokers {
+			conn, err := c.dialer.DialContext(ctx, "tcp", b)
+			if err == nil {
+				return conn.Close()
+			}
+			errs = append(errs, err)
+		}
+		return errors.Join(errs...)
+	}}
+}
+
+// groupCheck passes when the group of every subscription is stable and
+// this consumer is one of its members, i.e. it has partitions assigned or
+// is idle by choice.
+func (c *Consumer) groupCheck() health.Check {
+	return health.Check{Name: "kafka_group", Probe: func(ctx context.Context) error {
+		ids := make([]string, 0, len(c.subs))
+		for _, s := range c.subs {
+			ids = append(ids, s.group)
+		}
+		resp, err := c.client().DescribeGroups(ctx, &kafka.DescribeGroupsRequest{GroupIDs: ids})
+		if err != nil {
+			return err
+		}
+		found := map[string]bool{}
+		var errs []error
+		for _, g := range resp.Groups {
+			found[g.GroupID] = true
+			if err := c.memberOf(g); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		for _, id := range ids {
+			if !found[id] {
+				errs = append(errs, fmt.Errorf("group %q not found", id))
+			}
+		}
+		return errors.Join(errs...)
+	}}
+}
+
+func (c *Consumer) memberOf(g kafka.DescribeGroupsResponseGroup) error {
+	if g.Error != nil {
+		return g.Error
+	}
+	if g.GroupState != "Stable" {
+		return fmt.Errorf("group %q is %s", g.GroupID, g.GroupState)
+	}
+	for _, m := range g.Members {
+		if m.ClientID == c.clientID {
+			return nil
+		}
+	}
+	return fmt.Errorf("not a member of group %q", g.GroupID)
+}
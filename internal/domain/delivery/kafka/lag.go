@@ -0,0 +1,122 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"order-service/internal/domain/health"
+	"order-service/internal/domain/metrics"
+)
+
+type lagState struct {
+	byPartition map[int]int64
+	max         int64
+	// when the lag first went above the threshold, zero while below
+	overSince time.Time
+	alerting  bool
+}
+
+func (s *subscription) track(p *partition) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active[p.id] = p
+}
+
+func (s *subscription) untrack(p *partition) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active[p.id] == p {
+		delete(s.active, p.id)
+		delete(s.lag.byPartition, p.id)
+		metrics.KafkaLag.DeleteLabelValues(s.topic, strconv.Itoa(p.id))
+	}
+}
+
+func (c *Consumer) monitorLag(interval time.Duration) {
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for now := range t.C {
+		for _, s := range c.subs {
+			s.measureLag(now)
+		}
+	}
+}
+
+// measureLag takes the high-water mark each reader saw on its last fetch
+// and subtracts the partition's committed offset. Until a partition has
+// committed anything the reader's own position stands in.
+func (s *subscription) measureLag(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lag := make(map[int]int64, len(s.active))
+	var worst int64
+	for id, p := range s.active {
+		st := p.r.Stats()
+		n := st.Lag
+		if committed := p.committed.Load(); committed >= 0 {
+			n = st.Offset + st.Lag - committed
+		}
+		n = max(n, 0)
+		lag[id] = n
+		worst = max(worst, n)
+		metrics.KafkaLag.WithLabelValues(s.topic, strconv.Itoa(id)).Set(float64(n))
+	}
+	s.lag.byPartition, s.lag.max = lag, worst
+
+	threshold := s.c.cfg.Kafka.LagThreshold
+	sustain := time.Duration(s.c.cfg.Kafka.LagSustainSeconds) * time.Second
+	if threshold <= 0 || worst <= threshold {
+		if s.lag.alerting {
+			s.log.Infow("consumer lag back under threshold", "lag", worst, "threshold", threshold)
+		}
+		s.lag.overSince, s.lag.alerting = time.Time{}, false
+		return
+	}
+	if s.lag.overSince.IsZero() {
+		s.lag.overSince = now
+	}
+	if !s.lag.alerting && now.Sub(s.lag.overSince) >= sustain {
+		s.lag.alerting = true
+		s.log.Warnw("consumer lag over threshold", "lag", worst, "threshold", threshold,
+			"since", s.lag.overSince, "partitions", lag)
+	}
+}
+
+// lagCheck fails while the lag of some topic has been over
+// KAFKA_LAG_THRESHOLD for KAFKA_LAG_SUSTAIN_SECONDS and reports the lag
+// per partition either way.
+func (c *Consumer) lagCheck() health.Check {
+	return health.Check{
+		Name: "kafka_lag",
+		Probe: func(context.Context) error {
+			var errs []error
+			for _, s := range c.subs {
+				s.mu.Lock()
+				if s.lag.alerting {
+					errs = append(errs, fmt.Errorf("%s: lag %d over %d since %s",
+						s.topic, s.lag.max, c.cfg.Kafka.LagThreshold, s.lag.overSince.Format(time.RFC3339)))
+				}
+				s.mu.Unlock()
+			}
+			return errors.Join(errs...)
+		},
+		Detail: func() map[string]int64 {
+			out := map[string]int64{}
+			for _, s := range c.subs {
+				s.mu.Lock()
+				for id, n := range s.lag.byPartition {
+					out[s.topic+"/"+strconv.Itoa(id)] = n
+				}
+				s.mu.Unlock()
+			}
+			return out
+		},
+	}
+}
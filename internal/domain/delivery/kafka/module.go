@@ -1,12 +1,19 @@
 package kafka
 
-import "go.uber.org/fx"
+import (
+	"order-service/internal/domain/health"
+
+	"go.uber.org/fx"
+)
 
 func Module() fx.Option {
 	return fx.Module("kafka",
 		fx.Provide(
 			NewConsumer,
 			NewProducer,
+			health.AsCheck(func(c *Consumer) health.Check { return c.brokerCheck() }),
+			health.AsCheck(func(c *Consumer) health.Check { return c.groupCheck() }),
+			health.AsCheck(func(c *Consumer) health.Check { return c.lagCheck() }),
 		),
 		fx.Invoke(
 			func(c *Consumer) error { return c.OnStart() },
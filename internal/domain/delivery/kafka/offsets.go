@@ -0,0 +1,306 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"order-service/config"
+	"order-service/internal/domain/entities"
+	"order-service/internal/domain/logctx"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Position names a place in every partition of a topic: "earliest",
+// "latest", an RFC 3339 time (the first message at or after it) or
+// explicit offsets as "0=120,1=87"; partitions not listed are untouched.
+type Position struct {
+	spec    string
+	at      time.Time
+	offsets map[int]int64
+}
+
+func ParsePosition(s string) (Position, error) {
+	s = strings.TrimSpace(s)
+	switch s {
+	case "earliest", "latest":
+		return Position{spec: s}, nil
+	case "":
+		return Position{}, errors.New("empty position")
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return Position{spec: s, at: t}, nil
+	}
+	p := Position{spec: s, offsets: map[int]int64{}}
+	for _, part := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		id, err1 := strconv.Atoi(k)
+		off, err2 := strconv.ParseInt(v, 10, 64)
+		if !ok || err1 != nil || err2 != nil || id < 0 || off < 0 {
+			return Position{}, fmt.Errorf("bad position %q, want earliest, latest, RFC 3339 time or partition=offset,...", s)
+		}
+		p.offsets[id] = off
+	}
+	return p, nil
+}
+
+func (p Position) String() string { return p.spec }
+
+// resolve turns p into an absolute offset per partition of topic.
+func resolve(ctx context.Context, cl *kafka.Client, topic string, p Position) (map[int]int64, error) {
+	meta, err := cl.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, err
+	}
+	if len(meta.Topics) != 1 {
+		return nil, fmt.Errorf("topic %q not found", topic)
+	}
+	if err := meta.Topics[0].Error; err != nil {
+		return nil, fmt.Errorf("topic %q: %w", topic, err)
+	}
+	var reqs []kafka.OffsetRequest
+	for _, part := range meta.Topics[0].Partitions {
+		switch {
+		case p.offsets != nil:
+			continue
+		case p.spec == "earliest":
+			reqs = append(reqs, kafka.FirstOffsetOf(part.ID))
+		case p.spec == "latest":
+			reqs = append(reqs, kafka.LastOffsetOf(part.ID))
+		default:
+			reqs = append(reqs, kafka.TimeOffsetOf(part.ID, p.at))
+		}
+	}
+	if p.offsets != nil {
+		known := map[int]bool{}
+		for _, part := range meta.Topics[0].Partitions {
+			known[part.ID] = true
+		}
+		for id := range p.offsets {
+			if !known[id] {
+				return nil, fmt.Errorf("topic %q has no partition %d", topic, id)
+			}
+		}
+		return p.offsets, nil
+	}
+
+	// a time past the last message resolves to the end of the partition
+	ends := map[int]int64{}
+	if !p.at.IsZero() {
+		if ends, err = resolve(ctx, cl, topic, Position{spec: "latest"}); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := cl.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: map[string][]kafka.OffsetRequest{topic: reqs}})
+	if err != nil {
+		return nil, err
+	}
+	out := map[int]int64{}
+	for _, po := range resp.Topics[topic] {
+		if po.Error != nil {
+			return nil, fmt.Errorf("partition %d: %w", po.Partition, po.Error)
+		}
+		switch {
+		case p.spec == "earliest":
+			out[po.Partition] = po.FirstOffset
+		case p.spec == "latest":
+			out[po.Partition] = po.LastOffset
+		default:
+			// the first message at or after p.at; -1 means there is none
+			out[po.Partition] = ends[po.Partition]
+			for off := range po.Offsets {
+				if off >= 0 && off < out[po.Partition] {
+					out[po.Partition] = off
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// ResetOffsets commits to for every partition of topic on behalf of group.
+// Kafka only accepts such commits while the group has no members, so the
+// consumers have to be stopped first. It returns the offsets committed.
+func ResetOffsets(ctx context.Context, cfg *config.ConfigModel, group, topic string, to Position) (map[int]int64, error) {
+	tr, err := newTransport(cfg, "order-service-offsets")
+	if err != nil {
+		return nil, err
+	}
+	cl := &kafka.Client{Addr: kafka.TCP(cfg.Kafka.Brokers...), Transport: tr}
+
+	desc, err := cl.DescribeGroups(ctx, &kafka.DescribeGroupsRequest{GroupIDs: []string{group}})
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range desc.Groups {
+		if g.Error == nil && len(g.Members) > 0 {
+			return nil, fmt.Errorf("group %q has %d active members (%s), stop the consumers first", group, len(g.Members), g.GroupState)
+		}
+	}
+
+	offsets, err := resolve(ctx, cl, topic, to)
+	if err != nil {
+		return nil, err
+	}
+	commits := make([]kafka.OffsetCommit, 0, len(offsets))
+	for id, off := range offsets {
+		commits = append(commits, kafka.OffsetCommit{Partition: id, Offset: off})
+	}
+	sort.Slice(commits, func(i, j int) bool { return commits[i].Partition < commits[j].Partition })
+
+	resp, err := cl.OffsetCommit(ctx, &kafka.OffsetCommitRequest{
+		GroupID:      group,
+		GenerationID: -1,
+		Topics:       map[string][]kafka.OffsetCommit{topic: commits},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range resp.Topics[topic] {
+		if p.Error != nil {
+			return nil, fmt.Errorf("partition %d: %w", p.Partition, p.Error)
+		}
+	}
+	return offsets, nil
+}
+
+// ReplayStats counts what Replay read. Saved includes orders that were
+// stored already and skipped as unchanged; Skipped are messages that did
+// not decode or validate.
+type ReplayStats struct {
+	Messages int
+	Saved    int
+	Skipped  int
+}
+
+// Replay reads topic from from up to to, partitions in parallel, and saves
+// the orders through the use case in batches of batch, decoded as its
+// subscription says. It uses no consumer
+// group, so the live consumers and their offsets are not affected; orders
+// stored already are skipped by their content hash.
+func (c *Consumer) Replay(ctx context.Context, topic string, from, to Position, batch int) (ReplayStats, error) {
+	if batch <= 0 {
+		batch = 500
+	}
+	sub := c.subscriptionFor(topic)
+	if sub == nil {
+		return ReplayStats{}, fmt.Errorf("topic %q is not in KAFKA_SUBSCRIPTIONS, its decoder is unknown", topic)
+	}
+	cl := c.client()
+	starts, err := resolve(ctx, cl, topic, from)
+	if err != nil {
+		return ReplayStats{}, fmt.Errorf("from: %w", err)
+	}
+	ends, err := resolve(ctx, cl, topic, to)
+	if err != nil {
+		return ReplayStats{}, fmt.Errorf("to: %w", err)
+	}
+
+	var (
+		mu    sync.Mutex
+		total ReplayStats
+		errs  []error
+		wg    sync.WaitGroup
+	)
+	for id, start := range starts {
+		end, ok := ends[id]
+		if !ok || start >= end {
+			continue
+		}
+		wg.Add(1)
+		go func(id int, start, end int64) {
+			defer wg.Done()
+			st, err := sub.replay(ctx, id, start, end, batch)
+			mu.Lock()
+			defer mu.Unlock()
+			total.Messages += st.Messages
+			total.Saved += st.Saved
+			total.Skipped += st.Skipped
+			if err != nil {
+				errs = append(errs, fmt.Errorf("partition %d: %w", id, err))
+			}
+		}(id, start, end)
+	}
+	wg.Wait()
+	return total, errors.Join(errs...)
+}
+
+const replayIdle = 10 * time.Second
+
+func (s *subscription) replay(ctx context.Context, id int, start, end int64, batch int) (ReplayStats, error) {
+	var (
+		st    ReplayStats
+		c     = s.c
+		topic = s.topic
+	)
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   c.cfg.Kafka.Brokers,
+		Topic:     topic,
+		Partition: id,
+		MinBytes:  1,
+		MaxBytes:  10_000_000,
+		Dialer:    c.dialer,
+	})
+	defer r.Close()
+	if err := r.SetOffset(start); err != nil {
+		return st, err
+	}
+	c.log.Infow("replaying", "topic", topic, "partition", id, "from", start, "to", end)
+
+	var orders []*entities.Order
+	save := func(last int64) error {
+		if len(orders) == 0 {
+			return nil
+		}
+		bctx := logctx.WithRequestID(ctx, fmt.Sprintf("replay-%s-%d-%d", topic, id, last))
+		invalid, err := c.uc.SetBatch(bctx, orders)
+		if err != nil {
+			return err
+		}
+		st.Saved += len(orders) - len(invalid)
+		st.Skipped += len(invalid)
+		orders = orders[:0]
+		return nil
+	}
+
+	for {
+		fetchCtx, cancel := context.WithTimeout(ctx, replayIdle)
+		msg, err := r.FetchMessage(fetchCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				// offsets below the end that never arrive are compacted
+				// away or transaction markers
+				c.log.Infow("partition replayed, tail is empty", "topic", topic, "partition", id, "messages", st.Messages)
+				return st, save(end)
+			}
+			return st, err
+		}
+		st.Messages++
+		// no dead letters: they were sent when the message was first consumed
+		if ord, err := s.decoder.Decode(msg); err == nil {
+			orders = append(orders, ord)
+		} else if retryable(err) {
+			return st, fmt.Errorf("offset %d: %w", msg.Offset, err)
+		} else {
+			c.log.Warnw("undecodable message, skip", "topic", topic, "partition", id, "offset", msg.Offset, "error", err)
+			st.Skipped++
+		}
+		done := msg.Offset >= end-1
+		if len(orders) >= batch || done {
+			if err := save(msg.Offset); err != nil {
+				return st, fmt.Errorf("save up to offset %d: %w", msg.Offset, err)
+			}
+		}
+		if done {
+			c.log.Infow("partition replayed", "topic", topic, "partition", id, "messages", st.Messages)
+			return st, nil
+		}
+	}
+}
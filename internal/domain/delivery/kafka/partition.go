@@ -0,0 +1,233 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"order-service/internal/domain/entities"
+	"order-service/internal/domain/logctx"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// partition consumes one assigned partition for the life of a generation.
+type partition struct {
+	s *subscription
+	// offsets are committed through the generation
+	gen   committer
+	genID int32
+	id    int
+
+	r *kafka.Reader
+	// next offset to read as last committed, -1 while unknown
+	committed atomic.Int64
+}
+
+// committer is a *kafka.Generation outside of tests.
+type committer interface {
+	CommitOffsets(offsets map[string]map[int]int64) error
+}
+
+// run reads from offset until the generation ends. Saves and commits run
+// on a context of their own: the generation waits for them, so work that
+// started is finished and committed instead of being redone by the next
+// owner of the partition.
+func (p *partition) run(ctx context.Context, offset int64) {
+	cfg := p.s.c.cfg.Kafka
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:        cfg.Brokers,
+		Topic:          p.s.topic,
+		Partition:      p.id,
+		MinBytes:       1_000,
+		MaxBytes:       1_000_000,
+		MaxWait:        500 * time.Millisecond,
+		ReadBackoffMin: 500 * time.Millisecond,
+		ReadBackoffMax: 5 * time.Second,
+		Dialer:         p.s.c.dialer,
+	})
+	defer r.Close()
+	if err := r.SetOffset(offset); err != nil {
+		p.s.log.Errorw("set offset failed", "partition", p.id, "offset", offset, "error", err)
+		return
+	}
+	p.r = r
+	// FirstOffset and LastOffset are negative: nothing committed yet
+	p.committed.Store(max(offset, -1))
+	p.s.track(p)
+	defer p.s.untrack(p)
+	p.s.log.Infow("partition started", "partition", p.id, "offset", offset, "generation", p.genID)
+	defer p.s.log.Infow("partition stopped", "partition", p.id, "generation", p.genID)
+
+	size := cfg.BatchSize
+	due := time.Duration(cfg.BatchWaitMs) * time.Millisecond
+	if due <= 0 {
+		due = 100 * time.Millisecond
+	}
+
+	var (
+		batch   []kafka.Message
+		started time.Time
+		wait    backoff
+	)
+	for {
+		// with a batch open, stop waiting for more once it is due
+		fetchCtx, cancel := ctx, context.CancelFunc(func() {})
+		if len(batch) > 0 {
+			fetchCtx, cancel = context.WithDeadline(ctx, started.Add(due))
+		}
+		msg, err := r.FetchMessage(fetchCtx)
+		cancel()
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				if !p.flush(ctx, batch) {
+					return
+				}
+				batch = nil
+				continue
+			}
+			p.s.log.Warnw("read error, will retry", "partition", p.id, "error", err)
+			if !sleep(ctx, wait.next()) {
+				return
+			}
+			continue
+		}
+		wait.reset()
+
+		if size <= 1 {
+			if !p.retry(ctx, func() error { return p.handle(msg) }) {
+				return
+			}
+			continue
+		}
+		if len(batch) == 0 {
+			started = time.Now()
+		}
+		batch = append(batch, msg)
+		if len(batch) >= size {
+			if !p.flush(ctx, batch) {
+				return
+			}
+			batch = nil
+		}
+	}
+}
+
+// acquire takes one of the subscription's worker slots, if the number is
+// bounded.
+func (p *partition) acquire() func() {
+	if p.s.slots == nil {
+		return func() {}
+	}
+	p.s.slots <- struct{}{}
+	return func() { <-p.s.slots }
+}
+
+// commit stores the offset after msg, i.e. the next one to read.
+func (p *partition) commit(msg kafka.Message) {
+	err := p.gen.CommitOffsets(map[string]map[int]int64{p.s.topic: {p.id: msg.Offset + 1}})
+	if err != nil {
+		p.s.log.Errorw("commit failed", "partition", p.id, "offset", msg.Offset, "generation", p.genID, "error", err)
+		return
+	}
+	p.committed.Store(msg.Offset + 1)
+}
+
+// retry runs fn until it succeeds, waiting longer after every failure.
+// It gives up, reporting false, when the generation ends; the offset is
+// not committed then and the next owner of the partition starts over.
+func (p *partition) retry(ctx context.Context, fn func() error) bool {
+	var wait backoff
+	for {
+		err := fn()
+		if err == nil {
+			return true
+		}
+		d := wait.next()
+		p.s.log.Warnw("will retry", "partition", p.id, "in", d, "error", err)
+		if !sleep(ctx, d) {
+			return false
+		}
+	}
+}
+
+// handle saves one message and commits it. A storage or schema registry
+// error is returned for the message to be tried again: committing a later
+// offset would skip it for good.
+func (p *partition) handle(msg kafka.Message) error {
+	defer p.acquire()()
+
+	ord, err := p.s.decode(msg)
+	if err != nil {
+		return err
+	}
+	if ord == nil {
+		p.commit(msg)
+		return nil
+	}
+
+	p.s.log.Infow("received", "order_uid", ord.OrderId.String(), "key", string(msg.Key), "partition", msg.Partition, "offset", msg.Offset)
+
+	// correlates the use case and repository lines with the message
+	ctx := logctx.WithRequestID(context.Background(), fmt.Sprintf("kafka-%s-%d-%d", msg.Topic, msg.Partition, msg.Offset))
+	if err := p.s.c.uc.Set(ctx, ord); err != nil {
+		if errors.Is(err, entities.ErrValidation) {
+			p.s.log.Warnw("invalid order, skip", "order_uid", ord.OrderId.String(), "partition", msg.Partition, "offset", msg.Offset, "error", err)
+			p.commit(msg)
+			return nil
+		}
+		p.s.log.Errorw("save failed", "order_uid", ord.OrderId.String(), "offset", msg.Offset, "error", err)
+		return err
+	}
+	p.commit(msg)
+	return nil
+}
+
+// flush saves a batch in one transaction and commits its highest offset.
+// Messages that do not decode or validate are skipped as in one-by-one
+// mode. A failed save is retried with nothing committed; flush reports
+// false if the generation ends before the batch is saved.
+func (p *partition) flush(ctx context.Context, msgs []kafka.Message) bool {
+	if len(msgs) == 0 {
+		return true
+	}
+
+	first, last := msgs[0], msgs[len(msgs)-1]
+	orders := make([]*entities.Order, 0, len(msgs))
+	for _, msg := range msgs {
+		var ord *entities.Order
+		if !p.retry(ctx, func() (err error) { ord, err = p.s.decode(msg); return err }) {
+			return false
+		}
+		if ord != nil {
+			orders = append(orders, ord)
+		}
+	}
+
+	saved := p.retry(ctx, func() error {
+		defer p.acquire()()
+
+		ctx := logctx.WithRequestID(context.Background(), fmt.Sprintf("kafka-%s-%d-%d-%d", first.Topic, first.Partition, first.Offset, last.Offset))
+		invalid, err := p.s.c.uc.SetBatch(ctx, orders)
+		for _, ord := range invalid {
+			p.s.log.Warnw("invalid order, skip", "order_uid", ord.OrderId.String(), "partition", p.id)
+		}
+		if err != nil {
+			p.s.log.Errorw("save batch failed", "partition", p.id, "from_offset", first.Offset, "to_offset", last.Offset, "error", err)
+			return err
+		}
+		p.s.log.Infow("batch saved", "partition", p.id, "from_offset", first.Offset, "to_offset", last.Offset,
+			"messages", len(msgs), "invalid", len(invalid))
+		return nil
+	})
+	if saved {
+		p.commit(last)
+	}
+	return saved
+}
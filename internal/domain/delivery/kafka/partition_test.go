@@ -0,0 +1,231 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"order-service/internal/domain/entities"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+)
+
+// memSaver stands in for the use case. The first fail calls fail; orders
+// invalid reports are left out as the use case leaves out invalid ones.
+type memSaver struct {
+	mu      sync.Mutex
+	fail    int
+	invalid func(o *entities.Order) bool
+	// runs at the start of every call
+	onCall  func()
+	batches [][]*entities.Order
+	calls   int
+}
+
+func (s *memSaver) Set(ctx context.Context, o *entities.Order) error {
+	_, err := s.SetBatch(ctx, []*entities.Order{o})
+	return err
+}
+
+func (s *memSaver) SetBatch(_ context.Context, orders []*entities.Order) ([]*entities.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.onCall != nil {
+		s.onCall()
+	}
+	if s.calls <= s.fail {
+		return nil, fmt.Errorf("%w: connection reset", entities.ErrUnavailable)
+	}
+	var invalid, saved []*entities.Order
+	for _, o := range orders {
+		if s.invalid != nil && s.invalid(o) {
+			invalid = append(invalid, o)
+			continue
+		}
+		saved = append(saved, o)
+	}
+	s.batches = append(s.batches, saved)
+	return invalid, nil
+}
+
+// memCommitter records committed offsets.
+type memCommitter struct {
+	mu      sync.Mutex
+	commits []int64
+}
+
+func (c *memCommitter) CommitOffsets(offsets map[string]map[int]int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.commits = append(c.commits, offsets[testTopic][0])
+	return nil
+}
+
+func (c *memCommitter) get() []int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]int64(nil), c.commits...)
+}
+
+func newTestPartition(t *testing.T, uc *memSaver) (*partition, *memCommitter, *memDLQ) {
+	t.Helper()
+	dec, err := newDecoder("json", decoderEnv{cfg: testConfig(), log: zap.NewNop().Sugar()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, dlq := newTestSubscription(t, testConfig(), dec)
+	s.c.uc = uc
+	gen := &memCommitter{}
+	p := &partition{s: s, gen: gen, genID: 1, id: 0}
+	p.committed.Store(-1)
+	return p, gen, dlq
+}
+
+// orderMsg is an order message at offset; n picks the order id.
+func orderMsg(t *testing.T, offset int64, n int) kafka.Message {
+	t.Helper()
+	o := testOrder()
+	o.OrderId = uuid.MustParse(fmt.Sprintf("b563feb7-b2b8-4b6c-9f5d-%012d", n))
+	b, err := json.Marshal(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kafka.Message{Topic: testTopic, Partition: 0, Offset: offset, Value: b}
+}
+
+func ids(orders []*entities.Order) []string {
+	out := make([]string, len(orders))
+	for i, o := range orders {
+		out[i] = o.OrderId.String()[24:]
+	}
+	return out
+}
+
+func TestFlushCommitsHighestOffset(t *testing.T) {
+	uc := &memSaver{}
+	p, gen, _ := newTestPartition(t, uc)
+	batch := []kafka.Message{orderMsg(t, 10, 1), orderMsg(t, 11, 2), orderMsg(t, 12, 3)}
+
+	if !p.flush(context.Background(), batch) {
+		t.Fatal("flush gave up")
+	}
+	if got := gen.get(); !reflect.DeepEqual(got, []int64{13}) {
+		t.Errorf("commits %v, want one of 13", got)
+	}
+	if p.committed.Load() != 13 {
+		t.Errorf("committed = %d", p.committed.Load())
+	}
+	if len(uc.batches) != 1 || !reflect.DeepEqual(ids(uc.batches[0]), []string{"000000000001", "000000000002", "000000000003"}) {
+		t.Errorf("saved %v, want the batch in one call", uc.batches)
+	}
+	if !p.flush(context.Background(), nil) || len(gen.get()) != 1 {
+		t.Error("an empty batch is saved or committed")
+	}
+}
+
+func TestFlushRetriesWithoutCommit(t *testing.T) {
+	uc := &memSaver{fail: 1}
+	p, gen, dlq := newTestPartition(t, uc)
+	uc.onCall = func() {
+		if c := gen.get(); len(c) > 0 {
+			t.Errorf("offsets %v committed before the batch was saved", c)
+		}
+	}
+	batch := []kafka.Message{orderMsg(t, 10, 1), {Topic: testTopic, Offset: 11, Value: []byte("garbage")}, orderMsg(t, 12, 2)}
+
+	if !p.flush(context.Background(), batch) {
+		t.Fatal("flush gave up")
+	}
+	if uc.calls != 2 {
+		t.Errorf("%d saves, want a retry after the failure", uc.calls)
+	}
+	if got := gen.get(); !reflect.DeepEqual(got, []int64{13}) {
+		t.Errorf("commits %v, want one of 13", got)
+	}
+	if dlq.len() != 1 {
+		t.Errorf("%d dead letters, want the garbage once despite the retry", dlq.len())
+	}
+}
+
+func TestFlushGivesUpWhenGenerationEnds(t *testing.T) {
+	uc := &memSaver{fail: 1 << 30}
+	p, gen, _ := newTestPartition(t, uc)
+	ctx, cancel := context.WithCancel(context.Background())
+	uc.onCall = cancel
+
+	if p.flush(ctx, []kafka.Message{orderMsg(t, 10, 1), orderMsg(t, 11, 2)}) {
+		t.Fatal("flush reported an unsaved batch as done")
+	}
+	if c := gen.get(); len(c) > 0 {
+		t.Errorf("offsets %v committed past an unsaved batch", c)
+	}
+	if p.committed.Load() != -1 {
+		t.Errorf("committed = %d", p.committed.Load())
+	}
+}
+
+func TestFlushPartlyDeduplicated(t *testing.T) {
+	tests := []struct {
+		name    string
+		batch   func(t *testing.T) []kafka.Message
+		invalid func(o *entities.Order) bool
+		saved   []string
+		commit  int64
+	}{
+		{"repeated order last", func(t *testing.T) []kafka.Message {
+			return []kafka.Message{orderMsg(t, 20, 1), orderMsg(t, 21, 2), orderMsg(t, 22, 1)}
+		}, nil, []string{"000000000001", "000000000002", "000000000001"}, 23},
+		{"undecodable last", func(t *testing.T) []kafka.Message {
+			return []kafka.Message{orderMsg(t, 20, 1), {Topic: testTopic, Offset: 21, Value: []byte("{")}}
+		}, nil, []string{"000000000001"}, 22},
+		{"invalid orders", func(t *testing.T) []kafka.Message {
+			return []kafka.Message{orderMsg(t, 20, 1), orderMsg(t, 21, 2), orderMsg(t, 22, 3)}
+		}, func(o *entities.Order) bool { return o.OrderId.String()[24:] != "000000000002" },
+			[]string{"000000000002"}, 23},
+		{"nothing left to save", func(t *testing.T) []kafka.Message {
+			return []kafka.Message{{Topic: testTopic, Offset: 20, Value: []byte("[]")}, {Topic: testTopic, Offset: 21, Value: []byte("x")}}
+		}, nil, []string{}, 22},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := &memSaver{invalid: tt.invalid}
+			p, gen, _ := newTestPartition(t, uc)
+
+			if !p.flush(context.Background(), tt.batch(t)) {
+				t.Fatal("flush gave up")
+			}
+			if len(uc.batches) != 1 || !reflect.DeepEqual(ids(uc.batches[0]), tt.saved) {
+				t.Errorf("saved %v, want %v", uc.batches, tt.saved)
+			}
+			if got := gen.get(); !reflect.DeepEqual(got, []int64{tt.commit}) {
+				t.Errorf("commits %v, want one of %d", got, tt.commit)
+			}
+		})
+	}
+}
+
+func TestHandleRetriesWithoutCommit(t *testing.T) {
+	uc := &memSaver{fail: 1}
+	p, gen, _ := newTestPartition(t, uc)
+	msg := orderMsg(t, 7, 1)
+
+	if err := p.handle(msg); !errors.Is(err, entities.ErrUnavailable) {
+		t.Fatalf("handle = %v, want the save error", err)
+	}
+	if c := gen.get(); len(c) > 0 {
+		t.Fatalf("offsets %v committed past an unsaved message", c)
+	}
+	if !p.retry(context.Background(), func() error { return p.handle(msg) }) {
+		t.Fatal("retry gave up")
+	}
+	if got := gen.get(); !reflect.DeepEqual(got, []int64{8}) {
+		t.Errorf("commits %v, want one of 8", got)
+	}
+}
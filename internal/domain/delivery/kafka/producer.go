@@ -2,11 +2,11 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"math/rand"
 	"order-service/config"
 	"order-service/internal/domain/entities"
+	"order-service/internal/domain/schemaregistry"
 	"time"
 
 	"github.com/google/uuid"
@@ -24,28 +24,60 @@ func NewProducer(cfg *config.ConfigModel, l *zap.Logger) (*Producer, error) {
 }
 
 func (p *Producer) OnStart() error {
+	reg, err := schemaregistry.New(p.cfg)
+	if err != nil {
+		return fmt.Errorf("kafka: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), registryTimeout)
+	enc, err := newEncoder(ctx, p.cfg.Kafka.ProducerFormat, p.cfg.Kafka.Topic, reg)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("kafka: producer: %w", err)
+	}
+
+	dialer, err := newDialer(p.cfg, "order-service-producer")
+	if err != nil {
+		return err
+	}
+	tr, err := newTransport(p.cfg, "order-service-producer")
+	if err != nil {
+		return err
+	}
+
 	w := &kafka.Writer{
 		Addr:                   kafka.TCP(p.cfg.Kafka.Brokers...),
+		Transport:              tr,
 		Topic:                  p.cfg.Kafka.Topic,
 		Balancer:               &kafka.Hash{},
 		RequiredAcks:           kafka.RequireAll,
 		AllowAutoTopicCreation: true, // удобно для dev, см. примечание ниже
 	}
 
-	if err := waitTopicReady(context.Background(), p.cfg.Kafka.Brokers, p.cfg.Kafka.Topic, 30*time.Second, p.log); err != nil {
+	if err := waitTopicReady(context.Background(), dialer, p.cfg.Kafka.Brokers, p.cfg.Kafka.Topic, 30*time.Second, p.log); err != nil {
 		p.log.Warnw("kafka not ready yet, will try anyway", "error", err)
 	}
 
 	go func() {
 		defer w.Close()
 		interval := time.Second
-		p.log.Infow("emitting", "brokers", p.cfg.Kafka.Brokers, "topic", p.cfg.Kafka.Topic, "interval", interval)
+		p.log.Infow("emitting", "brokers", p.cfg.Kafka.Brokers, "topic", p.cfg.Kafka.Topic, "interval", interval,
+			"format", enc.contentType)
 
 		ctx := context.Background()
 		for n := 0; ; n++ {
 			ord := makeDummyOrder(n)
-			payload, _ := json.Marshal(ord)
-			msg := kafka.Message{Key: []byte(ord.OrderId.String()), Value: payload, Time: time.Now()}
+			payload, err := enc.encode(&ord)
+			if err != nil {
+				p.log.Errorw("encode failed", "order_uid", ord.OrderId.String(), "error", err)
+				time.Sleep(interval)
+				continue
+			}
+			msg := kafka.Message{
+				Key:     []byte(ord.OrderId.String()),
+				Value:   payload,
+				Time:    time.Now(),
+				Headers: []kafka.Header{{Key: "content-type", Value: []byte(enc.contentType)}},
+			}
 
 			if err := w.WriteMessages(ctx, msg); err != nil {
 				p.log.Errorw("send failed", "order_uid", ord.OrderId.String(), "error", err)
@@ -58,7 +90,7 @@ func (p *Producer) OnStart() error {
 	return nil
 }
 
-func waitTopicReady(ctx context.Context, brokers []string, topic string, timeout time.Duration, log *zap.SugaredLogger) error {
+func waitTopicReady(ctx context.Context, d *kafka.Dialer, brokers []string, topic string, timeout time.Duration, log *zap.SugaredLogger) error {
 	deadline := time.Now().Add(timeout)
 	backoff := 500 * time.Millisecond
 
@@ -66,7 +98,7 @@ func waitTopicReady(ctx context.Context, brokers []string, topic string, timeout
 		if time.Now().After(deadline) {
 			return fmt.Errorf("timeout waiting for topic %q", topic)
 		}
-		conn, err := kafka.DialContext(ctx, "tcp", brokers[0])
+		conn, err := d.DialContext(ctx, "tcp", brokers[0])
 		if err == nil {
 			parts, perr := conn.ReadPartitions(topic)
 			_ = conn.Close()
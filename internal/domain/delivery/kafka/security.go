@@ -0,0 +1,101 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"order-service/config"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+const dialTimeout = 10 * time.Second
+
+// newDialer connects readers, consumer groups and plain connections with
+// the TLS and SASL settings of cfg.
+func newDialer(cfg *config.ConfigModel, clientID string) (*kafka.Dialer, error) {
+	t, m, err := security(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &kafka.Dialer{ClientID: clientID, Timeout: dialTimeout, DualStack: true, TLS: t, SASLMechanism: m}, nil
+}
+
+// newTransport is newDialer for writers and kafka.Client.
+func newTransport(cfg *config.ConfigModel, clientID string) (*kafka.Transport, error) {
+	t, m, err := security(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &kafka.Transport{ClientID: clientID, DialTimeout: dialTimeout, TLS: t, SASL: m}, nil
+}
+
+// security returns the TLS config, nil when TLS is off, and the SASL
+// mechanism, nil when there is none.
+func security(cfg *config.ConfigModel) (*tls.Config, sasl.Mechanism, error) {
+	t, err := tlsConfig(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("kafka tls: %w", err)
+	}
+	m, err := saslMechanism(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("kafka sasl: %w", err)
+	}
+	return t, m, nil
+}
+
+func tlsConfig(cfg *config.ConfigModel) (*tls.Config, error) {
+	c := cfg.Kafka.TLS
+	if !c.Enabled {
+		return nil, nil
+	}
+	t := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: c.InsecureSkipVerify}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		t.RootCAs = x509.NewCertPool()
+		if !t.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no PEM certificates", c.CAFile)
+		}
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return nil, errors.New("client certificate and key go together")
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		t.Certificates = []tls.Certificate{cert}
+	}
+	return t, nil
+}
+
+func saslMechanism(cfg *config.ConfigModel) (sasl.Mechanism, error) {
+	c := cfg.Kafka.SASL
+	if c.Mechanism == "" {
+		return nil, nil
+	}
+	if c.Username == "" {
+		return nil, fmt.Errorf("%s needs a username", c.Mechanism)
+	}
+	switch strings.ToUpper(c.Mechanism) {
+	case "PLAIN":
+		return plain.Mechanism{Username: c.Username, Password: c.Password}, nil
+	case "SCRAM-SHA-256":
+		return scram.Mechanism(scram.SHA256, c.Username, c.Password)
+	case "SCRAM-SHA-512":
+		return scram.Mechanism(scram.SHA512, c.Username, c.Password)
+	}
+	return nil, fmt.Errorf("unknown mechanism %q, have PLAIN, SCRAM-SHA-256, SCRAM-SHA-512", c.Mechanism)
+}
@@ -0,0 +1,135 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"order-service/internal/domain/entities"
+
+	"github.com/google/uuid"
+)
+
+// Modes of KAFKA_JSON_STRICT.
+const (
+	strictOff  = "off"
+	strictWarn = "warn"
+	strictOn   = "strict"
+)
+
+// optionalKeys may be left out of an order; they are the fields with a
+// default in order.avsc. Every other key is mandatory.
+var optionalKeys = map[string]bool{
+	"$.internal_signature": true,
+	"$.payment.request_id": true,
+	"$.payment.custom_fee": true,
+}
+
+const maxProblems = 10
+
+var (
+	uuidType = reflect.TypeOf(uuid.UUID{})
+	timeType = reflect.TypeOf(time.Time{})
+)
+
+// checkOrderJSON compares b with the JSON form of entities.Order and
+// returns its problems as "$.path: what", unknown keys, missing mandatory
+// keys and values of the wrong type.
+func checkOrderJSON(b []byte) []string {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return []string{"$: " + err.Error()}
+	}
+	var probs []string
+	checkValue(v, reflect.TypeOf(entities.Order{}), "$", &probs)
+	if len(probs) > maxProblems {
+		probs = append(probs[:maxProblems], fmt.Sprintf("and %d more", len(probs)-maxProblems))
+	}
+	return probs
+}
+
+func checkValue(v any, t reflect.Type, path string, probs *[]string) {
+	bad := func(want string) {
+		*probs = append(*probs, fmt.Sprintf("%s: want %s, got %s", path, want, jsonKind(v)))
+	}
+	switch {
+	case t == uuidType || t == timeType || t.Kind() == reflect.String:
+		if _, ok := v.(string); !ok {
+			bad("string")
+		}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		n, ok := v.(json.Number)
+		if !ok {
+			bad("integer")
+		} else if _, err := n.Int64(); err != nil {
+			bad("integer")
+		}
+	case t.Kind() == reflect.Slice:
+		arr, ok := v.([]any)
+		if !ok {
+			bad("array")
+			return
+		}
+		for i, el := range arr {
+			checkValue(el, t.Elem(), fmt.Sprintf("%s[%d]", path, i), probs)
+		}
+	case t.Kind() == reflect.Struct:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			bad("object")
+			return
+		}
+		fields := map[string]reflect.Type{}
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if name != "" && name != "-" {
+				fields[name] = t.Field(i).Type
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			ft, known := fields[k]
+			if !known {
+				*probs = append(*probs, fmt.Sprintf("%s.%s: unknown field", path, k))
+				continue
+			}
+			checkValue(obj[k], ft, path+"."+k, probs)
+		}
+		names := make([]string, 0, len(fields))
+		for k := range fields {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			if _, ok := obj[k]; !ok && !optionalKeys[path+"."+k] {
+				*probs = append(*probs, fmt.Sprintf("%s.%s: missing", path, k))
+			}
+		}
+	}
+}
+
+func jsonKind(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	}
+	return "object"
+}
@@ -0,0 +1,173 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// orderJSON is testOrder as JSON after edit had its way with the document.
+func orderJSON(t *testing.T, edit func(m map[string]any)) []byte {
+	t.Helper()
+	b, err := json.Marshal(testOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	if edit != nil {
+		edit(m)
+	}
+	if b, err = json.Marshal(m); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func obj(m map[string]any, key string) map[string]any { return m[key].(map[string]any) }
+
+func item(m map[string]any, i int) map[string]any { return m["items"].([]any)[i].(map[string]any) }
+
+func TestCheckOrderJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(m map[string]any)
+		want []string
+	}{
+		{"valid", nil, nil},
+		{"optional keys left out", func(m map[string]any) {
+			delete(m, "internal_signature")
+			delete(obj(m, "payment"), "request_id")
+			delete(obj(m, "payment"), "custom_fee")
+		}, nil},
+		{"unknown key", func(m map[string]any) { m["order_id"] = "x" },
+			[]string{"$.order_id: unknown field"}},
+		{"misspelt key", func(m map[string]any) { m["order_id"] = m["order_uid"]; delete(m, "order_uid") },
+			[]string{"$.order_id: unknown field", "$.order_uid: missing"}},
+		{"missing mandatory keys", func(m map[string]any) {
+			delete(m, "track_number")
+			delete(obj(m, "delivery"), "email")
+			delete(m, "items")
+		}, []string{"$.delivery.email: missing", "$.items: missing", "$.track_number: missing"}},
+		{"integer as string", func(m map[string]any) { m["sm_id"] = "99" },
+			[]string{"$.sm_id: want integer, got string"}},
+		{"fractional integer", func(m map[string]any) { obj(m, "payment")["amount"] = 18.17 },
+			[]string{"$.payment.amount: want integer, got number"}},
+		{"uuid as number", func(m map[string]any) { m["order_uid"] = 1 },
+			[]string{"$.order_uid: want string, got number"}},
+		{"time as boolean", func(m map[string]any) { m["date_created"] = true },
+			[]string{"$.date_created: want string, got boolean"}},
+		{"null", func(m map[string]any) { m["locale"] = nil },
+			[]string{"$.locale: want string, got null"}},
+		{"object as array", func(m map[string]any) { m["delivery"] = []any{} },
+			[]string{"$.delivery: want object, got array"}},
+		{"array as object", func(m map[string]any) { m["items"] = map[string]any{} },
+			[]string{"$.items: want array, got object"}},
+		{"nested item fields", func(m map[string]any) {
+			m["items"] = append(m["items"].([]any), map[string]any{})
+			for k, v := range item(m, 0) {
+				item(m, 1)[k] = v
+			}
+			item(m, 0)["price"] = "453"
+			item(m, 1)["colour"] = "red"
+			delete(item(m, 1), "rid")
+		}, []string{
+			"$.items[0].price: want integer, got string",
+			"$.items[1].colour: unknown field",
+			"$.items[1].rid: missing",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := checkOrderJSON(orderJSON(t, tt.edit))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("problems:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckOrderJSONBroken(t *testing.T) {
+	for _, b := range []string{``, `{"order_uid":`, `[]`, `"order"`} {
+		probs := checkOrderJSON([]byte(b))
+		if len(probs) != 1 || !strings.HasPrefix(probs[0], "$") {
+			t.Errorf("%q: problems %q, want one about $", b, probs)
+		}
+	}
+}
+
+func TestCheckOrderJSONCap(t *testing.T) {
+	b := orderJSON(t, func(m map[string]any) {
+		for i := range maxProblems + 5 {
+			m[fmt.Sprintf("extra_%02d", i)] = i
+		}
+	})
+	probs := checkOrderJSON(b)
+	if len(probs) != maxProblems+1 {
+		t.Fatalf("%d problems, want %d and a count: %q", len(probs), maxProblems, probs)
+	}
+	if probs[0] != "$.extra_00: unknown field" || probs[maxProblems] != "and 5 more" {
+		t.Errorf("problems %q", probs)
+	}
+}
+
+func TestJSONDecoderModes(t *testing.T) {
+	bad := orderJSON(t, func(m map[string]any) { m["order_id"] = "x" })
+	wrapped, _ := json.Marshal(map[string]any{"event": "order_created", "data": json.RawMessage(bad)})
+
+	tests := []struct {
+		mode     string
+		envelope bool
+		fails    bool
+		warns    bool
+	}{
+		{strictOff, false, false, false},
+		{strictWarn, false, false, true},
+		{strictOn, false, true, false},
+		{strictWarn, true, false, true},
+		{strictOn, true, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s envelope=%v", tt.mode, tt.envelope), func(t *testing.T) {
+			core, logs := observer.New(zapcore.WarnLevel)
+			cfg := testConfig()
+			cfg.Kafka.JSONStrict = tt.mode
+			d, err := newJSONDecoder(decoderEnv{cfg: cfg, log: zap.New(core).Sugar()})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			msg := kafka.Message{Topic: testTopic, Value: bad}
+			decode := d.Decode
+			if tt.envelope {
+				msg.Value, decode = wrapped, d.decodeEnvelope
+			}
+			ord, err := decode(msg)
+			if tt.fails {
+				if err == nil || !strings.Contains(err.Error(), "$.order_id: unknown field") {
+					t.Fatalf("err = %v, want the problem", err)
+				}
+			} else if err != nil || ord.OrderId != testOrder().OrderId {
+				t.Fatalf("decode = %v, %v; want the order", ord, err)
+			}
+			if warned := logs.FilterMessage("order json does not match the contract").Len() > 0; warned != tt.warns {
+				t.Errorf("warned %v, want %v", warned, tt.warns)
+			}
+		})
+	}
+
+	cfg := testConfig()
+	cfg.Kafka.JSONStrict = "loose"
+	if _, err := newJSONDecoder(decoderEnv{cfg: cfg, log: zap.NewNop().Sugar()}); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
@@ -0,0 +1,144 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"order-service/config"
+	"order-service/internal/domain/entities"
+	"order-service/internal/domain/metrics"
+
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+)
+
+// subscription consumes one topic in its own consumer group and maps the
+// messages to orders with its decoder.
+type subscription struct {
+	c       *Consumer
+	topic   string
+	group   string
+	workers int
+	decoder Decoder
+	log     *zap.SugaredLogger
+
+	// bounds concurrent saves across partitions; nil means one per partition
+	slots chan struct{}
+
+	// partitions of the current generation, for lag monitoring
+	mu     sync.Mutex
+	active map[int]*partition
+	lag    lagState
+}
+
+func newSubscription(c *Consumer, sc config.KafkaSubscription, dec Decoder) *subscription {
+	s := &subscription{
+		c:       c,
+		topic:   sc.Topic,
+		group:   sc.Group,
+		workers: sc.Workers,
+		decoder: dec,
+		log:     c.log.With("topic", sc.Topic),
+		active:  map[int]*partition{},
+	}
+	if sc.Workers > 0 {
+		s.slots = make(chan struct{}, sc.Workers)
+	}
+	return s
+}
+
+// start joins the group. Every generation gets one goroutine per assigned
+// partition, so a slow save holds up its own partition only; keys map to
+// partitions, so per key the topic order is kept. Offsets are committed
+// through the generation, and a rebalance waits for the partitions'
+// in-flight work before the group moves on.
+func (s *subscription) start() error {
+	group, err := kafka.NewConsumerGroup(kafka.ConsumerGroupConfig{
+		ID:                    s.group,
+		Brokers:               s.c.cfg.Kafka.Brokers,
+		Topics:                []string{s.topic},
+		Dialer:                s.c.dialer,
+		StartOffset:           kafka.LastOffset,
+		WatchPartitionChanges: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	_ = waitTopicReady(context.Background(), s.c.dialer, s.c.cfg.Kafka.Brokers, s.topic, 30*time.Second, s.log)
+
+	go func() {
+		defer group.Close()
+		s.log.Infow("listening", "brokers", s.c.cfg.Kafka.Brokers, "group", s.group,
+			"batch_size", s.c.cfg.Kafka.BatchSize, "workers", s.workers)
+
+		var (
+			ctx  = context.Background()
+			wait backoff
+		)
+		for {
+			gen, err := group.Next(ctx)
+			if err != nil {
+				if errors.Is(err, kafka.ErrGroupClosed) {
+					return
+				}
+				d := wait.next()
+				s.log.Warnw("join group failed, will retry", "group", s.group, "in", d, "error", err)
+				time.Sleep(d)
+				continue
+			}
+			wait.reset()
+			assigned := gen.Assignments[s.topic]
+			s.log.Infow("partitions assigned", "generation", gen.ID, "partitions", len(assigned))
+			for _, a := range assigned {
+				p := &partition{s: s, gen: gen, genID: gen.ID, id: a.ID}
+				offset := a.Offset
+				gen.Start(func(ctx context.Context) { p.run(ctx, offset) })
+			}
+		}
+	}()
+	return nil
+}
+
+// decode maps msg to an order. Messages that do not decode are counted,
+// copied to the dead letter topic if there is one, and skipped: the order
+// is nil then. An error is returned only when decoding failed for reasons
+// outside the message, such as the schema registry being down; the
+// message has to be tried again.
+func (s *subscription) decode(msg kafka.Message) (*entities.Order, error) {
+	ord, err := s.decoder.Decode(msg)
+	if err == nil {
+		return ord, nil
+	}
+	if retryable(err) {
+		return nil, err
+	}
+	s.log.Warnw("undecodable message, skip", "partition", msg.Partition, "offset", msg.Offset, "error", err)
+	metrics.KafkaDecodeFailures.WithLabelValues(s.topic).Inc()
+	if s.c.dlq != nil {
+		s.deadLetter(msg, err)
+	}
+	return nil, nil
+}
+
+// deadLetter copies msg with its headers to the dead letter topic and adds
+// where it came from and why it failed. A failed write is only logged: the
+// message is committed either way so the partition is not stuck on it.
+func (s *subscription) deadLetter(msg kafka.Message, cause error) {
+	headers := append(append([]kafka.Header(nil), msg.Headers...),
+		kafka.Header{Key: "x-error", Value: []byte(cause.Error())},
+		kafka.Header{Key: "x-topic", Value: []byte(msg.Topic)},
+		kafka.Header{Key: "x-partition", Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: "x-offset", Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := s.c.dlq.WriteMessages(ctx, kafka.Message{Key: msg.Key, Value: msg.Value, Headers: headers})
+	if err != nil {
+		s.log.Errorw("dead letter failed, message dropped", "partition", msg.Partition, "offset", msg.Offset,
+			"dlq", s.c.cfg.Kafka.DLQTopic, "error", err)
+	}
+}
@@ -0,0 +1,216 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/config"
+	"order-service/internal/domain/entities"
+	"order-service/internal/domain/schemaregistry"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+)
+
+const testTopic = "orders"
+
+func testOrder() *entities.Order {
+	return &entities.Order{
+		OrderId:         uuid.MustParse("b563feb7-b2b8-4b6c-9f5d-000000000001"),
+		TrackNumber:     "WBILMTESTTRACK",
+		Entry:           "WBIL",
+		Locale:          "en",
+		CustomerId:      "test",
+		DeliveryService: "meest",
+		ShardKey:        9,
+		SmId:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		Delivery: entities.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: entities.Payment{
+			TransactionId: "b563feb7b2b84b6ctest", Currency: "USD", Provider: "wbpay", Amount: 1817,
+			PaymentDt: 1637907727, Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317,
+		},
+		Items: []entities.Item{{
+			ChrtId: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, RID: "ab4219087a764ae0btest",
+			Name: "Mascaras", Sale: 30, Size: "0", TotalPrice: 317, NmID: 2389212, Brand: "Vivienne Sabo", Status: 202,
+		}},
+	}
+}
+
+// memDLQ records dead letters.
+type memDLQ struct {
+	mu   sync.Mutex
+	msgs []kafka.Message
+}
+
+func (d *memDLQ) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.msgs = append(d.msgs, msgs...)
+	return nil
+}
+
+func (d *memDLQ) len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.msgs)
+}
+
+// downRegistry is a registry that cannot be reached.
+type downRegistry struct{}
+
+func (downRegistry) ByID(context.Context, int) (schemaregistry.Schema, error) {
+	return schemaregistry.Schema{}, errors.New("dial tcp 127.0.0.1:8081: connect: connection refused")
+}
+
+func (downRegistry) Register(context.Context, string, schemaregistry.Schema) (int, error) {
+	return 0, errors.New("dial tcp 127.0.0.1:8081: connect: connection refused")
+}
+
+func testConfig() *config.ConfigModel {
+	cfg := &config.ConfigModel{}
+	cfg.Kafka.JSONStrict = strictOff
+	cfg.Kafka.DLQTopic = testTopic + "-dlq"
+	return cfg
+}
+
+func newTestSubscription(t *testing.T, cfg *config.ConfigModel, dec Decoder) (*subscription, *memDLQ) {
+	t.Helper()
+	dlq := &memDLQ{}
+	c := &Consumer{cfg: cfg, log: zap.NewNop().Sugar(), dlq: dlq}
+	return newSubscription(c, config.KafkaSubscription{Topic: testTopic, Group: "test"}, dec), dlq
+}
+
+func openRegistry(t *testing.T) *schemaregistry.FileRegistry {
+	t.Helper()
+	reg, err := schemaregistry.OpenFile(filepath.Join(t.TempDir(), "schemas.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reg
+}
+
+func TestDecodeDeadLetters(t *testing.T) {
+	reg := openRegistry(t)
+	dec, err := newDecoder("auto", decoderEnv{cfg: testConfig(), reg: reg, log: zap.NewNop().Sugar()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, dlq := newTestSubscription(t, testConfig(), dec)
+
+	msg := kafka.Message{
+		Topic: testTopic, Partition: 3, Offset: 42, Key: []byte("k"),
+		Value:   frame(99, nil, []byte{0}),
+		Headers: []kafka.Header{{Key: "content-type", Value: []byte(contentTypeAvro)}},
+	}
+	ord, err := s.decode(msg)
+	if ord != nil || err != nil {
+		t.Fatalf("decode = %v, %v; want skipped", ord, err)
+	}
+	if dlq.len() != 1 {
+		t.Fatalf("%d dead letters, want 1", dlq.len())
+	}
+	got := dlq.msgs[0]
+	if string(got.Key) != "k" || string(got.Value) != string(msg.Value) {
+		t.Errorf("dead letter %q=%q is not the original message", got.Key, got.Value)
+	}
+	want := map[string]string{
+		"content-type": contentTypeAvro,
+		"x-topic":      testTopic,
+		"x-partition":  "3",
+		"x-offset":     "42",
+	}
+	for k, v := range want {
+		if h := header(got, k); h != v {
+			t.Errorf("header %s = %q, want %q", k, h, v)
+		}
+	}
+	if h := header(got, "x-error"); !strings.Contains(h, "schema 99") {
+		t.Errorf("x-error = %q", h)
+	}
+}
+
+func TestDecodeRegistryDown(t *testing.T) {
+	for _, name := range []string{"avro", "protobuf", "auto"} {
+		t.Run(name, func(t *testing.T) {
+			dec, err := newDecoder(name, decoderEnv{cfg: testConfig(), reg: downRegistry{}, log: zap.NewNop().Sugar()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, dlq := newTestSubscription(t, testConfig(), dec)
+			msg := kafka.Message{Topic: testTopic, Value: frame(1, []int{0}, nil)}
+			if name != "protobuf" {
+				msg.Value = frame(1, nil, nil)
+				msg.Headers = []kafka.Header{{Key: "content-type", Value: []byte(contentTypeAvro)}}
+			}
+
+			ord, err := s.decode(msg)
+			if err == nil || !retryable(err) {
+				t.Fatalf("decode = %v, %v; want a retryable error", ord, err)
+			}
+			if dlq.len() != 0 {
+				t.Errorf("message dead-lettered while the registry is down")
+			}
+		})
+	}
+}
+
+// flakyRegistry is reg, unreachable while down is set.
+type flakyRegistry struct {
+	schemaregistry.Registry
+	mu   sync.Mutex
+	down bool
+}
+
+func (r *flakyRegistry) setDown(v bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.down = v
+}
+
+func (r *flakyRegistry) ByID(ctx context.Context, id int) (schemaregistry.Schema, error) {
+	r.mu.Lock()
+	down := r.down
+	r.mu.Unlock()
+	if down {
+		return downRegistry{}.ByID(ctx, id)
+	}
+	return r.Registry.ByID(ctx, id)
+}
+
+func TestDecodeRegistryRecovers(t *testing.T) {
+	reg := &flakyRegistry{Registry: openRegistry(t)}
+	enc, err := newEncoder(context.Background(), "avro", testTopic, reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := enc.encode(testOrder())
+	dec, err := newAvroDecoder(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, dlq := newTestSubscription(t, testConfig(), dec)
+	msg := kafka.Message{Topic: testTopic, Value: b}
+
+	reg.setDown(true)
+	if _, err := s.decode(msg); !retryable(err) {
+		t.Fatalf("err = %v, want retryable", err)
+	}
+	reg.setDown(false)
+	ord, err := s.decode(msg)
+	if err != nil || ord == nil || ord.OrderId != testOrder().OrderId {
+		t.Fatalf("decode after recovery = %v, %v", ord, err)
+	}
+	if dlq.len() != 0 {
+		t.Error("message dead-lettered")
+	}
+}
@@ -0,0 +1,60 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Confluent wire format: a zero magic byte, the schema id as a big-endian
+// uint32, for protobuf the index path of the message type within its
+// schema, then the encoded message.
+const wireMagic = 0
+
+// frame wraps payload written with schema id. indexes is nil for Avro.
+func frame(id int, indexes []int, payload []byte) []byte {
+	b := make([]byte, 5, 5+len(payload)+8)
+	b[0] = wireMagic
+	binary.BigEndian.PutUint32(b[1:], uint32(id))
+	switch {
+	case indexes == nil:
+	case len(indexes) == 1 && indexes[0] == 0:
+		// the common first-message case is shortened to a bare count of 0
+		b = append(b, 0)
+	default:
+		b = binary.AppendVarint(b, int64(len(indexes)))
+		for _, i := range indexes {
+			b = binary.AppendVarint(b, int64(i))
+		}
+	}
+	return append(b, payload...)
+}
+
+// unframe splits a framed message into its schema id and the rest.
+func unframe(b []byte) (int, []byte, error) {
+	if len(b) < 5 || b[0] != wireMagic {
+		return 0, nil, errors.New("not in schema registry wire format")
+	}
+	return int(binary.BigEndian.Uint32(b[1:5])), b[5:], nil
+}
+
+// messageIndexes reads the protobuf index path that follows the schema id.
+func messageIndexes(b []byte) ([]int, []byte, error) {
+	n, k := binary.Varint(b)
+	if k <= 0 || n < 0 || n > 64 {
+		return nil, nil, errors.New("bad protobuf message indexes")
+	}
+	b = b[k:]
+	if n == 0 {
+		return []int{0}, b, nil
+	}
+	idx := make([]int, n)
+	for i := range idx {
+		v, k := binary.Varint(b)
+		if k <= 0 {
+			return nil, nil, fmt.Errorf("bad protobuf message index %d", i)
+		}
+		idx[i], b = int(v), b[k:]
+	}
+	return idx, b, nil
+}
@@ -0,0 +1,25 @@
+package entities
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrNotFound    = errors.New("order not found")
+	ErrInvalidID   = errors.New("invalid order id")
+	ErrValidation  = errors.New("validation failed")
+	ErrUnavailable = errors.New("storage unavailable")
+)
+
+// ValidationError lists every problem found in an order. It matches
+// ErrValidation with errors.Is.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return ErrValidation.Error() + ": " + strings.Join(e.Problems, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool { return target == ErrValidation }
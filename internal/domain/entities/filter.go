@@ -0,0 +1,57 @@
+package entities
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type OrderFilter struct {
+	CustomerId      string
+	DeliveryService string
+	TrackNumber     string
+	Email           string
+	Phone           string
+	MinAmount       int64
+	From            time.Time
+	To              time.Time
+
+	Limit int
+	After *PageCursor
+}
+
+// PageCursor is the position of the last order of a page in the
+// (date_created DESC, order_uid DESC) listing order.
+type PageCursor struct {
+	DateCreated time.Time
+	OrderId     uuid.UUID
+}
+
+func (c PageCursor) Encode() string {
+	raw := strconv.FormatInt(c.DateCreated.UnixNano(), 10) + "|" + c.OrderId.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodePageCursor(s string) (*PageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("bad page token: %w", err)
+	}
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, fmt.Errorf("bad page token")
+	}
+	n, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("bad page token: %w", err)
+	}
+	u, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("bad page token: %w", err)
+	}
+	return &PageCursor{DateCreated: time.Unix(0, n).UTC(), OrderId: u}, nil
+}
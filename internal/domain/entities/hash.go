@@ -0,0 +1,28 @@
+package entities
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"hash"
+)
+
+// ContentHash is an HMAC-SHA256 over the order's JSON form, so two messages
+// carrying the same order hash alike however they were formatted. The JSON
+// holds delivery PII; keying the hash keeps the stored value from
+// confirming guessed names or phones. A nil key gives plain SHA-256, for
+// databases where that PII is stored in plaintext anyway.
+func (o *Order) ContentHash(key []byte) []byte {
+	b, err := json.Marshal(o)
+	if err != nil {
+		return nil
+	}
+	var h hash.Hash
+	if key == nil {
+		h = sha256.New()
+	} else {
+		h = hmac.New(sha256.New, key)
+	}
+	h.Write(b)
+	return h.Sum(nil)
+}
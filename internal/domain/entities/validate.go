@@ -0,0 +1,60 @@
+package entities
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Validate checks the order against the constraints of the storage schema.
+func (o *Order) Validate() error {
+	var p []string
+	add := func(format string, args ...any) { p = append(p, fmt.Sprintf(format, args...)) }
+	maxLen := func(field, v string, n int) {
+		if len(v) > n {
+			add("%s longer than %d", field, n)
+		}
+	}
+
+	if o.OrderId == uuid.Nil {
+		add("order_uid is required")
+	}
+	if o.TrackNumber == "" {
+		add("track_number is required")
+	}
+	maxLen("track_number", o.TrackNumber, 32)
+	maxLen("entry", o.Entry, 16)
+	maxLen("locale", o.Locale, 8)
+	maxLen("customer_id", o.CustomerId, 64)
+	maxLen("delivery_service", o.DeliveryService, 32)
+	if o.ShardKey < -32768 || o.ShardKey > 32767 {
+		add("shardkey out of range")
+	}
+
+	maxLen("delivery.zip", o.Delivery.Zip, 16)
+
+	maxLen("payment.transaction_id", o.Payment.TransactionId, 64)
+	maxLen("payment.request_id", o.Payment.RequestId, 64)
+	if c := o.Payment.Currency; c != "" && len(c) != 3 {
+		add("payment.currency must be 3 letters")
+	}
+	maxLen("payment.provider", o.Payment.Provider, 32)
+	maxLen("payment.bank", o.Payment.Bank, 64)
+	if o.Payment.Amount < 0 || o.Payment.DeliveryCost < 0 || o.Payment.GoodsTotal < 0 || o.Payment.CustomFee < 0 {
+		add("payment amounts must not be negative")
+	}
+
+	for i, it := range o.Items {
+		maxLen(fmt.Sprintf("items[%d].track_number", i), it.TrackNumber, 32)
+		maxLen(fmt.Sprintf("items[%d].rid", i), it.RID, 64)
+		maxLen(fmt.Sprintf("items[%d].size", i), it.Size, 16)
+		if it.Price < 0 || it.TotalPrice < 0 {
+			add("items[%d] prices must not be negative", i)
+		}
+	}
+
+	if len(p) > 0 {
+		return &ValidationError{Problems: p}
+	}
+	return nil
+}
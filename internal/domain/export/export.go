@@ -0,0 +1,127 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"order-service/internal/domain/entities"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// Writer turns orders into one export format. Close flushes what is
+// buffered; it does not close the underlying writer.
+type Writer interface {
+	Write(o *entities.Order) error
+	Close() error
+}
+
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return nil, err
+		}
+		return &csvWriter{w: cw}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown export format %q, want csv or ndjson", format)
+	}
+}
+
+func ContentType(format string) string {
+	if format == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(o *entities.Order) error { return n.enc.Encode(o) }
+func (n *ndjsonWriter) Close() error                  { return nil }
+
+var csvHeader = []string{
+	"order_uid", "track_number", "entry", "locale", "customer_id", "delivery_service",
+	"shardkey", "sm_id", "date_created",
+	"delivery_name", "delivery_phone", "delivery_zip", "delivery_city", "delivery_address",
+	"delivery_region", "delivery_email",
+	"payment_transaction", "payment_request_id", "payment_currency", "payment_provider",
+	"payment_amount", "payment_dt", "payment_bank", "payment_delivery_cost",
+	"payment_goods_total", "payment_custom_fee",
+	"item_chrt_id", "item_track_number", "item_price", "item_rid", "item_name", "item_sale",
+	"item_size", "item_total_price", "item_nm_id", "item_brand", "item_status",
+}
+
+// csvWriter flattens an order into one row per item, repeating the order,
+// delivery and payment columns. Orders without items get one row with the
+// item columns empty.
+type csvWriter struct {
+	w   *csv.Writer
+	row []string
+}
+
+func (c *csvWriter) Write(o *entities.Order) error {
+	i64 := func(v int64) string { return strconv.FormatInt(v, 10) }
+	itoa := strconv.Itoa
+
+	c.row = append(c.row[:0],
+		o.OrderId.String(), o.TrackNumber, o.Entry, o.Locale, o.CustomerId, o.DeliveryService,
+		i64(o.ShardKey), itoa(o.SmId), o.DateCreated.UTC().Format(time.RFC3339),
+		o.Delivery.Name, o.Delivery.Phone, o.Delivery.Zip, o.Delivery.City, o.Delivery.Address,
+		o.Delivery.Region, o.Delivery.Email,
+		o.Payment.TransactionId, o.Payment.RequestId, o.Payment.Currency, o.Payment.Provider,
+		i64(o.Payment.Amount), i64(o.Payment.PaymentDt), o.Payment.Bank, i64(o.Payment.DeliveryCost),
+		i64(o.Payment.GoodsTotal), i64(o.Payment.CustomFee),
+	)
+	head := len(c.row)
+
+	if len(o.Items) == 0 {
+		for len(c.row) < len(csvHeader) {
+			c.row = append(c.row, "")
+		}
+		return c.w.Write(c.row)
+	}
+	for _, it := range o.Items {
+		c.row = append(c.row[:head],
+			i64(it.ChrtId), it.TrackNumber, i64(it.Price), it.RID, it.Name, itoa(it.Sale),
+			it.Size, i64(it.TotalPrice), i64(it.NmID), it.Brand, itoa(it.Status),
+		)
+		if err := c.w.Write(c.row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ParseTime reads a range bound as RFC 3339 or a plain date (midnight UTC).
+// Empty means unbounded.
+func ParseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad time %q, want RFC 3339 or YYYY-MM-DD", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,126 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"order-service/config"
+
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// Check probes one dependency. Modules contribute checks to the "health"
+// group with AsCheck.
+type Check struct {
+	Name  string
+	Probe func(ctx context.Context) error
+	// Detail optionally adds figures to the result, e.g. lag per partition.
+	Detail func() map[string]int64
+}
+
+// AsCheck annotates a constructor returning a Check for the health group.
+func AsCheck(f any) any {
+	return fx.Annotate(f, fx.ResultTags(`group:"health"`))
+}
+
+const (
+	StatusOK          = "ok"
+	StatusDegraded    = "degraded"
+	StatusUnavailable = "unavailable"
+	StatusDown        = "down"
+)
+
+type CheckResult struct {
+	Status    string           `json:"status"`
+	Critical  bool             `json:"critical"`
+	LatencyMs int64            `json:"latency_ms"`
+	Error     string           `json:"error,omitempty"`
+	Detail    map[string]int64 `json:"detail,omitempty"`
+}
+
+// Report is ok when every check passed, degraded when only non-critical
+// ones failed and unavailable when a critical one did.
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+func (r Report) Ready() bool { return r.Status != StatusUnavailable }
+
+type Checker struct {
+	checks   []Check
+	critical map[string]bool
+	timeout  time.Duration
+	log      *zap.SugaredLogger
+}
+
+type params struct {
+	fx.In
+
+	Checks []Check `group:"health"`
+}
+
+func NewChecker(cfg *config.ConfigModel, p params, l *zap.Logger) *Checker {
+	c := &Checker{
+		checks:   p.Checks,
+		critical: map[string]bool{},
+		timeout:  time.Duration(cfg.Health.TimeoutMs) * time.Millisecond,
+		log:      l.Named("health").Sugar(),
+	}
+	for _, name := range cfg.Health.Critical {
+		c.critical[name] = true
+	}
+	names := make([]string, 0, len(c.checks))
+	for _, ch := range c.checks {
+		names = append(names, ch.Name)
+	}
+	c.log.Infow("health checks registered", "checks", names, "critical", cfg.Health.Critical)
+	return c
+}
+
+// Run probes every dependency concurrently, each bounded by the timeout.
+func (c *Checker) Run(ctx context.Context) Report {
+	rep := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(c.checks))}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, ch := range c.checks {
+		wg.Add(1)
+		go func(ch Check) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := ch.Probe(ctx)
+			res := CheckResult{Status: StatusOK, Critical: c.critical[ch.Name], LatencyMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				res.Status = StatusDown
+				res.Error = err.Error()
+			}
+			if ch.Detail != nil {
+				res.Detail = ch.Detail()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			rep.Checks[ch.Name] = res
+			switch {
+			case err == nil:
+			case res.Critical:
+				rep.Status = StatusUnavailable
+			case rep.Status == StatusOK:
+				rep.Status = StatusDegraded
+			}
+		}(ch)
+	}
+	wg.Wait()
+
+	if rep.Status != StatusOK {
+		c.log.Warnw("not healthy", "status", rep.Status, "checks", rep.Checks)
+	}
+	return rep
+}
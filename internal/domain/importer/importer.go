@@ -0,0 +1,223 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"order-service/internal/domain/entities"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type store interface {
+	ImportProgress(ctx context.Context, source string) (int64, error)
+	ImportBatch(ctx context.Context, source string, line int64, orders []*entities.Order) error
+}
+
+// cache holds copies of stored orders that an import makes stale.
+type cache interface {
+	Invalidate(ctx context.Context, ids []uuid.UUID) error
+}
+
+// Options of one import run.
+type Options struct {
+	// NDJSON file, optionally gzip compressed
+	Path string
+	// orders per transaction
+	Batch int
+	// rejected lines are appended here as NDJSON {line, error}
+	ErrorsPath string
+}
+
+// Stats of one run; lines skipped on resume are not counted.
+type Stats struct {
+	Resumed  int64
+	Imported int
+	Rejected int
+}
+
+type Importer struct {
+	store store
+	cache cache
+	log   *zap.SugaredLogger
+}
+
+func New(s store, c cache, l *zap.Logger) *Importer {
+	return &Importer{store: s, cache: c, log: l.Named("import").Sugar()}
+}
+
+type rejected struct {
+	Line  int64  `json:"line"`
+	Error string `json:"error"`
+}
+
+// Run imports opt.Path. Progress is stored with every batch, keyed by the
+// file's name, size and a hash of its head, so running it again after an interruption picks
+// up after the last committed batch. A line that is not an order or fails
+// validation goes to the error file and does not stop the import.
+func (im *Importer) Run(ctx context.Context, opt Options) (Stats, error) {
+	var st Stats
+	if opt.Batch <= 0 {
+		opt.Batch = 1000
+	}
+
+	f, err := os.Open(opt.Path)
+	if err != nil {
+		return st, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return st, err
+	}
+	head, err := headHash(f)
+	if err != nil {
+		return st, err
+	}
+	source := fmt.Sprintf("%s:%d:%s", filepath.Base(opt.Path), fi.Size(), head)
+
+	done, err := im.store.ImportProgress(ctx, source)
+	if err != nil {
+		return st, fmt.Errorf("read progress: %w", err)
+	}
+	st.Resumed = done
+	if done > 0 {
+		im.log.Infow("resuming", "source", source, "after_line", done)
+	}
+
+	rd, err := decompress(f)
+	if err != nil {
+		return st, err
+	}
+
+	errFile, err := os.OpenFile(opt.ErrorsPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return st, err
+	}
+	defer errFile.Close()
+	errOut := json.NewEncoder(errFile)
+
+	var (
+		batch  []*entities.Order
+		index  = map[string]int{}
+		reject []rejected
+		line   int64
+	)
+	// rejected lines are written once their batch is committed, so a
+	// resumed run does not report them twice
+	flush := func() error {
+		if line <= done {
+			return nil
+		}
+		if err := im.store.ImportBatch(ctx, source, line, batch); err != nil {
+			return fmt.Errorf("batch ending at line %d: %w", line, err)
+		}
+		if len(batch) > 0 {
+			ids := make([]uuid.UUID, len(batch))
+			for i, o := range batch {
+				ids[i] = o.OrderId
+			}
+			if err := im.cache.Invalidate(ctx, ids); err != nil {
+				return fmt.Errorf("batch ending at line %d is stored but its orders may be cached stale: %w", line, err)
+			}
+		}
+		for _, rj := range reject {
+			if err := errOut.Encode(rj); err != nil {
+				return err
+			}
+		}
+		st.Imported += len(batch)
+		st.Rejected += len(reject)
+		batch, reject = batch[:0], reject[:0]
+		clear(index)
+		return nil
+	}
+
+	for {
+		raw, readErr := rd.ReadBytes('\n')
+		if len(raw) > 0 {
+			line++
+		}
+		if len(bytes.TrimSpace(raw)) > 0 && line > done {
+			if o, err := parse(raw); err != nil {
+				reject = append(reject, rejected{Line: line, Error: err.Error()})
+			} else if i, ok := index[o.OrderId.String()]; ok {
+				// a later line for the same order wins, as it would in Kafka
+				batch[i] = o
+			} else {
+				index[o.OrderId.String()] = len(batch)
+				batch = append(batch, o)
+			}
+			if len(batch)+len(reject) >= opt.Batch {
+				if err := flush(); err != nil {
+					return st, err
+				}
+				im.log.Infow("progress", "line", line, "imported", st.Imported, "rejected", st.Rejected)
+			}
+		}
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil {
+			return st, readErr
+		}
+		if err := ctx.Err(); err != nil {
+			return st, err
+		}
+	}
+	// the last batch also records the final line, even if it only held
+	// rejected lines
+	if err := flush(); err != nil {
+		return st, err
+	}
+	return st, nil
+}
+
+// headHash fingerprints the first 64 KiB of f, so files that share name
+// and size do not share progress, and rewinds f.
+func headHash(f *os.File) (string, error) {
+	h := sha256.New()
+	if _, err := io.CopyN(h, f, 64<<10); err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8]), nil
+}
+
+func parse(raw []byte) (*entities.Order, error) {
+	var o entities.Order
+	if err := json.Unmarshal(raw, &o); err != nil {
+		return nil, fmt.Errorf("bad json: %w", err)
+	}
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// decompress sniffs the gzip magic rather than trusting the file name.
+func decompress(r io.Reader) (*bufio.Reader, error) {
+	br := bufio.NewReaderSize(r, 1<<20)
+	magic, err := br.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return bufio.NewReaderSize(zr, 1<<20), nil
+	}
+	return br, nil
+}
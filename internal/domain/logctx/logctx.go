@@ -0,0 +1,52 @@
+package logctx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"go.uber.org/zap"
+)
+
+type ctxKey struct{}
+
+// WithRequestID stores the id of the HTTP or gRPC call (or Kafka message)
+// being handled so loggers further down can tag their lines with it.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// From returns l with the request id of ctx attached, or l itself when
+// there is none.
+func From(ctx context.Context, l *zap.SugaredLogger) *zap.SugaredLogger {
+	if id := RequestID(ctx); id != "" {
+		return l.With("request_id", id)
+	}
+	return l
+}
+
+// NewID returns a random request id.
+func NewID() string {
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Accept reports whether an id sent by a client is safe to adopt: short
+// and printable ASCII, so it cannot break log lines or headers.
+func Accept(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
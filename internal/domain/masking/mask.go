@@ -0,0 +1,85 @@
+package masking
+
+import "strings"
+
+const redacted = "***"
+
+// maskPhone keeps the leading "+" and country digit and the last four
+// digits: +79161231234 -> +7******1234.
+func maskPhone(s string, m Mode) string {
+	switch {
+	case s == "" || m == None:
+		return s
+	case m == Redact:
+		return redacted
+	}
+	r := []rune(s)
+	head := 1
+	if r[0] == '+' {
+		head = 2
+	}
+	if len(r) <= head+4 {
+		return stars(len(r))
+	}
+	return string(r[:head]) + stars(len(r)-head-4) + string(r[len(r)-4:])
+}
+
+// maskEmail keeps the first letter of the local part and the domain:
+// ivan.petrov@example.com -> i***@example.com.
+func maskEmail(s string, m Mode) string {
+	switch {
+	case s == "" || m == None:
+		return s
+	case m == Redact:
+		return redacted
+	}
+	local, domain, ok := strings.Cut(s, "@")
+	if !ok || local == "" {
+		return redacted
+	}
+	return string([]rune(local)[:1]) + redacted + "@" + domain
+}
+
+// maskName keeps initials: Ivan Petrov -> I. P.
+func maskName(s string, m Mode) string {
+	switch {
+	case s == "" || m == None:
+		return s
+	case m == Redact:
+		return redacted
+	}
+	parts := strings.Fields(s)
+	for i, p := range parts {
+		parts[i] = string([]rune(p)[:1]) + "."
+	}
+	return strings.Join(parts, " ")
+}
+
+// maskAddress keeps the first word, usually the street type or name.
+func maskAddress(s string, m Mode) string {
+	switch {
+	case s == "" || m == None:
+		return s
+	case m == Redact:
+		return redacted
+	}
+	first, _, _ := strings.Cut(strings.TrimSpace(s), " ")
+	return first + " " + redacted
+}
+
+// maskTail keeps the last four characters.
+func maskTail(s string, m Mode) string {
+	switch {
+	case s == "" || m == None:
+		return s
+	case m == Redact:
+		return redacted
+	}
+	r := []rune(s)
+	if len(r) <= 4 {
+		return stars(len(r))
+	}
+	return stars(len(r)-4) + string(r[len(r)-4:])
+}
+
+func stars(n int) string { return strings.Repeat("*", n) }
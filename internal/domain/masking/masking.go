@@ -0,0 +1,180 @@
+package masking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"order-service/config"
+	"order-service/internal/domain/auth"
+	"order-service/internal/domain/entities"
+
+	"go.uber.org/zap"
+)
+
+type Mode int
+
+// Modes are ordered from most to least revealing.
+const (
+	None Mode = iota
+	Partial
+	Redact
+)
+
+var modes = map[string]Mode{"none": None, "partial": Partial, "redact": Redact}
+
+const (
+	FieldName          = "name"
+	FieldPhone         = "phone"
+	FieldEmail         = "email"
+	FieldAddress       = "address"
+	FieldTransactionID = "transaction_id"
+)
+
+var fields = []string{FieldName, FieldPhone, FieldEmail, FieldAddress, FieldTransactionID}
+
+type rule map[string]Mode
+
+// Policy decides per role how much of each PII field a caller sees. A
+// caller with several roles gets the most revealing mode any of them
+// allows; roles without a rule see everything redacted.
+type Policy struct {
+	rules       map[string]rule
+	defaultRole string
+	log         *zap.SugaredLogger
+}
+
+func NewPolicy(cfg *config.ConfigModel, l *zap.Logger) (*Policy, error) {
+	p := &Policy{
+		rules:       map[string]rule{},
+		defaultRole: cfg.Masking.DefaultRole,
+		log:         l.Named("masking").Sugar(),
+	}
+	for role, spec := range cfg.Masking.Rules {
+		r := rule{}
+		for _, f := range fields {
+			r[f] = Redact
+		}
+		for _, part := range strings.Split(spec, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			field, mode, ok := strings.Cut(part, ":")
+			if !ok {
+				return nil, fmt.Errorf("masking: role %q: bad rule %q, want field:mode", role, part)
+			}
+			m, ok := modes[mode]
+			if !ok {
+				return nil, fmt.Errorf("masking: role %q: unknown mode %q", role, mode)
+			}
+			if field == "*" {
+				for _, f := range fields {
+					r[f] = m
+				}
+				continue
+			}
+			if _, ok := r[field]; !ok {
+				return nil, fmt.Errorf("masking: role %q: unknown field %q", role, field)
+			}
+			r[field] = m
+		}
+		p.rules[role] = r
+	}
+	p.log.Infow("masking policy loaded", "roles", len(p.rules), "default_role", p.defaultRole)
+	return p, nil
+}
+
+// Apply returns o as the caller in ctx may see it. o itself is never
+// modified; it may be shared with the cache.
+func (p *Policy) Apply(ctx context.Context, o *entities.Order) *entities.Order {
+	if o == nil {
+		return nil
+	}
+	r := p.ruleFor(ctx)
+	if r.reveals() {
+		return o
+	}
+	out := *o
+	out.Delivery = r.delivery(out.Delivery)
+	out.Payment = r.payment(out.Payment)
+	return &out
+}
+
+// Delivery is Apply for resolvers that load deliveries on their own.
+func (p *Policy) Delivery(ctx context.Context, d entities.Delivery) entities.Delivery {
+	return p.ruleFor(ctx).delivery(d)
+}
+
+// Payment is Apply for resolvers that load payments on their own.
+func (p *Policy) Payment(ctx context.Context, pay entities.Payment) entities.Payment {
+	return p.ruleFor(ctx).payment(pay)
+}
+
+// ErrHiddenFilter rejects an exact-match filter on a field the caller only
+// sees masked: matches would confirm the values masking hides.
+var ErrHiddenFilter = errors.New("filter on a masked field")
+
+// CheckFilter fails when f filters by email or phone and the caller in
+// ctx may not see that field in full.
+func (p *Policy) CheckFilter(ctx context.Context, f entities.OrderFilter) error {
+	r := p.ruleFor(ctx)
+	for _, c := range []struct{ field, value string }{
+		{FieldEmail, f.Email},
+		{FieldPhone, f.Phone},
+	} {
+		if c.value != "" && r[c.field] != None {
+			return fmt.Errorf("%w: %s", ErrHiddenFilter, c.field)
+		}
+	}
+	return nil
+}
+
+func (r rule) reveals() bool {
+	for _, f := range fields {
+		if r[f] != None {
+			return false
+		}
+	}
+	return true
+}
+
+func (r rule) delivery(d entities.Delivery) entities.Delivery {
+	d.Name = maskName(d.Name, r[FieldName])
+	d.Phone = maskPhone(d.Phone, r[FieldPhone])
+	d.Email = maskEmail(d.Email, r[FieldEmail])
+	d.Address = maskAddress(d.Address, r[FieldAddress])
+	return d
+}
+
+func (r rule) payment(p entities.Payment) entities.Payment {
+	p.TransactionId = maskTail(p.TransactionId, r[FieldTransactionID])
+	return p
+}
+
+func (p *Policy) ruleFor(ctx context.Context) rule {
+	var roles []string
+	if pr := auth.FromContext(ctx); pr != nil {
+		roles = pr.Roles
+	} else if p.defaultRole != "" {
+		roles = []string{p.defaultRole}
+	}
+
+	out := rule{}
+	for _, f := range fields {
+		out[f] = Redact
+	}
+	for _, role := range roles {
+		r, ok := p.rules[role]
+		if !ok {
+			continue
+		}
+		for f, m := range r {
+			if m < out[f] {
+				out[f] = m
+			}
+		}
+	}
+	return out
+}
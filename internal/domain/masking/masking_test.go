@@ -0,0 +1,57 @@
+package masking
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"order-service/config"
+	"order-service/internal/domain/auth"
+	"order-service/internal/domain/entities"
+
+	"go.uber.org/zap"
+)
+
+func TestCheckFilter(t *testing.T) {
+	cfg := &config.ConfigModel{}
+	cfg.Masking.Rules = map[string]string{
+		auth.RoleAdmin:   "*:none",
+		auth.RoleSupport: "*:partial,email:none",
+		auth.RoleReader:  "*:redact",
+	}
+	p, err := NewPolicy(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	as := func(roles ...string) context.Context {
+		return auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "t", Roles: roles})
+	}
+	email := entities.OrderFilter{Email: "test@gmail.com"}
+	phone := entities.OrderFilter{Phone: "+9720000000"}
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		f      entities.OrderFilter
+		hidden bool
+	}{
+		{"admin email", as(auth.RoleAdmin), email, false},
+		{"admin phone", as(auth.RoleAdmin), phone, false},
+		{"support email", as(auth.RoleSupport), email, false},
+		{"support partial phone", as(auth.RoleSupport), phone, true},
+		{"reader email", as(auth.RoleReader), email, true},
+		{"reader phone", as(auth.RoleReader), phone, true},
+		{"reader and admin", as(auth.RoleReader, auth.RoleAdmin), phone, false},
+		{"reader other filters", as(auth.RoleReader), entities.OrderFilter{CustomerId: "test"}, false},
+		{"role without rule", as(auth.RoleIngest), email, true},
+		{"anonymous without default role", context.Background(), email, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.CheckFilter(tt.ctx, tt.f)
+			if got := errors.Is(err, ErrHiddenFilter); got != tt.hidden {
+				t.Errorf("err = %v, want hidden %v", err, tt.hidden)
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+package masking
+
+import "go.uber.org/fx"
+
+func Module() fx.Option {
+	return fx.Module("masking",
+		fx.Provide(NewPolicy),
+	)
+}
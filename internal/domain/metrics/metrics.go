@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "order_service"
+
+// IngestDuplicates counts orders skipped because the stored copy already
+// has the same content, mostly Kafka messages replayed after a lost commit.
+var IngestDuplicates = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "ingest_duplicates_total",
+	Help:      "Orders skipped on ingest because their content was already stored.",
+})
+
+// KafkaLag is, per partition this replica consumes, the high-water mark
+// minus the committed offset.
+var KafkaLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Name:      "kafka_consumer_lag",
+	Help:      "Messages between the committed offset and the high-water mark of a partition.",
+}, []string{"topic", "partition"})
+
+// KafkaDecodeFailures counts messages skipped because they did not decode:
+// bad framing, unknown or incompatible schemas, malformed payloads.
+var KafkaDecodeFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "kafka_decode_failures_total",
+	Help:      "Kafka messages that could not be decoded into an order.",
+}, []string{"topic"})
+
+// KafkaJSONViolations counts JSON orders that break the contract checked by
+// KAFKA_JSON_STRICT, by the mode they were found in: refused in strict,
+// only logged in warn.
+var KafkaJSONViolations = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "kafka_json_violations_total",
+	Help:      "JSON orders with unknown, missing or mistyped fields.",
+}, []string{"topic", "mode"})
+
+// Handler serves the default registry in the Prometheus text format.
+func Handler() http.Handler { return promhttp.Handler() }
@@ -0,0 +1,200 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"order-service/config"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// DefaultRoute is the rule for routes without their own.
+const DefaultRoute = "default"
+
+// Rule is a token bucket: Rate tokens per second refill a bucket holding at
+// most Burst. A zero Rule means unlimited.
+type Rule struct {
+	Rate  float64
+	Burst int
+}
+
+func (r Rule) Unlimited() bool { return r.Rate <= 0 || r.Burst <= 0 }
+
+// Result describes the bucket after a request was counted.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is the wait until the next token when not allowed.
+	RetryAfter time.Duration
+	// Reset is the wait until the bucket is full again.
+	Reset time.Duration
+}
+
+// Limiter keeps one bucket per client and route in Redis so all replicas
+// share it. When Redis is unreachable it counts in process memory instead:
+// limits then hold per replica, which beats either failing every request
+// or letting everything through.
+type Limiter struct {
+	enabled  bool
+	rules    map[string]Rule
+	rdb      *redis.Client
+	prefix   string
+	local    *memoryStore
+	degraded atomic.Bool
+	// while degraded, Redis is tried again after this unix nano time
+	retryAt atomic.Int64
+	log     *zap.SugaredLogger
+}
+
+func NewLimiter(cfg *config.ConfigModel, l *zap.Logger) (*Limiter, error) {
+	lim := &Limiter{
+		enabled: cfg.RateLimit.Enabled,
+		rules:   map[string]Rule{},
+		prefix:  cfg.RateLimit.KeyPrefix,
+		local:   newMemoryStore(),
+		log:     l.Named("ratelimit").Sugar(),
+	}
+	if !lim.enabled {
+		lim.log.Warnw("rate limiting disabled")
+		return lim, nil
+	}
+	for route, spec := range cfg.RateLimit.Rules {
+		r, err := parseRule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("ratelimit: route %q: %w", route, err)
+		}
+		lim.rules[route] = r
+	}
+	lim.rdb = redis.NewClient(&redis.Options{
+		Addr:     cfg.Redis.Addr,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+		// a limiter answer that comes late is worse than a local one
+		MaxRetries: -1,
+	})
+	lim.log.Infow("rate limiting enabled", "rules", cfg.RateLimit.Rules)
+	return lim, nil
+}
+
+func (l *Limiter) Enabled() bool { return l.enabled }
+
+// RuleFor returns the rule for route, e.g. "GET /order/{uid}".
+func (l *Limiter) RuleFor(route string) Rule {
+	if r, ok := l.rules[route]; ok {
+		return r
+	}
+	return l.rules[DefaultRoute]
+}
+
+// Allow takes one token from the bucket of client on route.
+func (l *Limiter) Allow(ctx context.Context, route, client string, rule Rule) Result {
+	key := l.prefix + route + ":" + client
+	now := time.Now()
+
+	if l.degraded.Load() && now.UnixNano() < l.retryAt.Load() {
+		return result(rule, l.local.take(key, rule, now))
+	}
+	tokens, err := l.takeRedis(ctx, key, rule)
+	if err != nil {
+		l.retryAt.Store(now.Add(redisRetry).UnixNano())
+		if !l.degraded.Swap(true) {
+			l.log.Warnw("redis unavailable, limiting in memory", "error", err)
+		}
+		return result(rule, l.local.take(key, rule, now))
+	}
+	if l.degraded.Swap(false) {
+		l.log.Infow("redis back, limiting shared again")
+	}
+	return result(rule, tokens)
+}
+
+const redisRetry = 5 * time.Second
+
+// tokenBucket refills the bucket for the time since its last use, then
+// takes a token if there is one. It returns the tokens left, negative when
+// the request was refused. Time comes from the Redis server so replica
+// clocks do not matter.
+var tokenBucket = redis.NewScript(`
+local rate  = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t     = redis.call('TIME')
+local now   = tonumber(t[1]) + tonumber(t[2]) / 1e6
+
+local b      = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(b[1]) or burst
+local ts     = tonumber(b[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+
+local left = tokens - 1
+if left >= 0 then
+  tokens = left
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('EXPIRE', KEYS[1], math.ceil(burst / rate) + 1)
+return tostring(left)
+`)
+
+func (l *Limiter) takeRedis(ctx context.Context, key string, rule Rule) (float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	s, err := tokenBucket.Run(ctx, l.rdb, []string{key}, rule.Rate, rule.Burst).Text()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// result turns the tokens left after a take into headers' worth of data.
+func result(rule Rule, left float64) Result {
+	res := Result{Allowed: left >= 0, Limit: rule.Burst}
+	if res.Allowed {
+		res.Remaining = int(left)
+	} else {
+		res.RetryAfter = seconds(-left / rule.Rate)
+		left = 0
+	}
+	res.Reset = seconds((float64(rule.Burst) - left) / rule.Rate)
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s)) * time.Second
+}
+
+// parseRule reads "20/s:40", i.e. 20 requests per second with bursts of
+// 40. Units are s, m and h; the burst defaults to the per-unit count.
+// "off" disables limiting.
+func parseRule(spec string) (Rule, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "off" {
+		return Rule{}, nil
+	}
+	rate, burst, hasBurst := strings.Cut(spec, ":")
+	count, unit, ok := strings.Cut(rate, "/")
+	if !ok {
+		return Rule{}, fmt.Errorf("bad rule %q, want count/unit[:burst]", spec)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		return Rule{}, fmt.Errorf("bad count in %q", spec)
+	}
+	per := map[string]float64{"s": 1, "m": 60, "h": 3600}[unit]
+	if per == 0 {
+		return Rule{}, fmt.Errorf("bad unit in %q, want s, m or h", spec)
+	}
+	r := Rule{Rate: float64(n) / per, Burst: n}
+	if hasBurst {
+		if r.Burst, err = strconv.Atoi(burst); err != nil || r.Burst <= 0 {
+			return Rule{}, fmt.Errorf("bad burst in %q", spec)
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens float64
+	ts     time.Time
+	idle   time.Duration
+}
+
+// memoryStore is the same token bucket as the Redis script, per process.
+type memoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{buckets: map[string]*bucket{}}
+}
+
+func (m *memoryStore) take(key string, rule Rule, now time.Time) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rule.Burst), ts: now}
+		m.buckets[key] = b
+	}
+	b.idle = time.Duration(float64(rule.Burst)/rule.Rate*float64(time.Second)) + time.Second
+	b.tokens = math.Min(float64(rule.Burst), b.tokens+math.Max(0, now.Sub(b.ts).Seconds())*rule.Rate)
+	b.ts = now
+
+	left := b.tokens - 1
+	if left >= 0 {
+		b.tokens = left
+	}
+	return left
+}
+
+// sweep drops buckets that have refilled completely; they are the same as
+// no bucket at all.
+func (m *memoryStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	m.lastSweep = now
+	for k, b := range m.buckets {
+		if now.Sub(b.ts) > b.idle {
+			delete(m.buckets, k)
+		}
+	}
+}
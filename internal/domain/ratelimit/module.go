@@ -0,0 +1,9 @@
+package ratelimit
+
+import "go.uber.org/fx"
+
+func Module() fx.Option {
+	return fx.Module("ratelimit",
+		fx.Provide(NewLimiter),
+	)
+}
@@ -53,6 +53,8 @@ func NewRedisCache(cfg *config.ConfigModel, l *zap.Logger) *RedisCache {
 
 func (c *RedisCache) key(id string) string { return c.prefix + id }
 
+func (c *RedisCache) Ping(ctx context.Context) error { return c.rdb.Ping(ctx).Err() }
+
 
 func (c *RedisCache) Get(id string) (*entities.Order, bool) {
 	ctx := context.Background()
@@ -88,3 +90,75 @@ func (c *RedisCache) Set(id string, order *entities.Order) {
 	}
 	c.log.Infow("cache set", "order_uid", id)
 }
+
+// GetMany fetches ids with a single MGET; misses and undecodable entries
+// are left out of the result.
+func (c *RedisCache) GetMany(ids []string) map[string]*entities.Order {
+	out := make(map[string]*entities.Order, len(ids))
+	if len(ids) == 0 {
+		return out
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = c.key(id)
+	}
+	vals, err := c.rdb.MGet(context.Background(), keys...).Result()
+	if err != nil {
+		c.log.Warnw("redis mget failed", "keys", len(keys), "error", err)
+		return out
+	}
+	for i, v := range vals {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var o entities.Order
+		if err := json.Unmarshal([]byte(s), &o); err != nil {
+			c.log.Errorw("unmarshal failed", "order_uid", ids[i], "error", err)
+			continue
+		}
+		out[ids[i]] = &o
+	}
+	c.log.Debugw("cache mget", "requested", len(ids), "hits", len(out))
+	return out
+}
+
+// Delete drops ids from the cache in one round trip.
+func (c *RedisCache) Delete(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = c.key(id)
+	}
+	if err := c.rdb.Del(ctx, keys...).Err(); err != nil {
+		return err
+	}
+	c.log.Infow("cache delete", "count", len(ids))
+	return nil
+}
+
+// SetMany stores orders in one pipelined round trip.
+func (c *RedisCache) SetMany(orders []*entities.Order) {
+	if len(orders) == 0 {
+		return
+	}
+	ctx := context.Background()
+	_, err := c.rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for _, o := range orders {
+			b, err := json.Marshal(o)
+			if err != nil {
+				c.log.Errorw("marshal failed", "order_uid", o.OrderId, "error", err)
+				continue
+			}
+			p.Set(ctx, c.key(o.OrderId.String()), b, c.ttl)
+		}
+		return nil
+	})
+	if err != nil {
+		c.log.Errorw("redis pipeline set failed", "count", len(orders), "error", err)
+		return
+	}
+	c.log.Infow("cache set many", "count", len(orders))
+}
@@ -0,0 +1,240 @@
+package pii
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"order-service/config"
+
+	"go.uber.org/zap"
+)
+
+// Keyring does envelope encryption of PII columns. Every row gets its own
+// data key (DEK); the DEK is stored wrapped by a key encryption key (KEK)
+// from the key file, next to the id of that KEK. Rotating means adding a
+// new KEK, making it active and re-wrapping rows.
+//
+// Key file:
+//
+//	{"active": "2025-01", "keys": {"2025-01": "<base64 32 bytes>"}, "index_key": "<base64 32 bytes>"}
+type Keyring struct {
+	active     string
+	keks       map[string]cipher.AEAD
+	indexKey   []byte
+	contentKey []byte
+}
+
+type keyFile struct {
+	Active   string            `json:"active"`
+	Keys     map[string]string `json:"keys"`
+	IndexKey string            `json:"index_key"`
+}
+
+// NewKeyring loads the key file from config. Without one it returns nil,
+// which every method treats as "store plaintext".
+func NewKeyring(cfg *config.ConfigModel, l *zap.Logger) (*Keyring, error) {
+	log := l.Named("pii").Sugar()
+	if cfg.PII.KeyFile == "" {
+		log.Warnw("PII_KEY_FILE not set, delivery PII is stored in plaintext")
+		return nil, nil
+	}
+	k, err := LoadKeyring(cfg.PII.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	log.Infow("pii keyring loaded", "active", k.active, "keys", len(k.keks))
+	return k, nil
+}
+
+func LoadKeyring(path string) (*Keyring, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("pii: %w", err)
+	}
+	var f keyFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("pii: parse %s: %w", path, err)
+	}
+	if _, ok := f.Keys[f.Active]; !ok {
+		return nil, fmt.Errorf("pii: active key %q not in %s", f.Active, path)
+	}
+
+	k := &Keyring{active: f.Active, keks: make(map[string]cipher.AEAD, len(f.Keys))}
+	for id, enc := range f.Keys {
+		raw, err := decodeKey(enc)
+		if err != nil {
+			return nil, fmt.Errorf("pii: key %q: %w", id, err)
+		}
+		aead, err := newAEAD(raw)
+		if err != nil {
+			return nil, fmt.Errorf("pii: key %q: %w", id, err)
+		}
+		k.keks[id] = aead
+	}
+	if k.indexKey, err = decodeKey(f.IndexKey); err != nil {
+		return nil, fmt.Errorf("pii: index_key: %w", err)
+	}
+	m := hmac.New(sha256.New, k.indexKey)
+	m.Write([]byte("content_hash"))
+	k.contentKey = m.Sum(nil)
+	return k, nil
+}
+
+func (k *Keyring) Enabled() bool { return k != nil }
+
+func (k *Keyring) ActiveKey() string {
+	if k == nil {
+		return ""
+	}
+	return k.active
+}
+
+// Envelope holds one row's DEK, unwrapped.
+type Envelope struct {
+	KeyID      string
+	WrappedDEK []byte
+	dek        cipher.AEAD
+}
+
+// NewEnvelope makes a fresh DEK wrapped with the active KEK.
+func (k *Keyring) NewEnvelope() (*Envelope, error) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	wrapped, err := seal(k.keks[k.active], dek, []byte(k.active))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{KeyID: k.active, WrappedDEK: wrapped, dek: aead}, nil
+}
+
+// OpenEnvelope unwraps a stored DEK.
+func (k *Keyring) OpenEnvelope(keyID string, wrapped []byte) (*Envelope, error) {
+	kek, ok := k.keks[keyID]
+	if !ok {
+		return nil, fmt.Errorf("pii: unknown key %q", keyID)
+	}
+	dek, err := open(kek, wrapped, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("pii: unwrap with %q: %w", keyID, err)
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{KeyID: keyID, WrappedDEK: wrapped, dek: aead}, nil
+}
+
+// Encrypt returns base64 ciphertext fit for a TEXT column. The column name
+// is bound as associated data so values cannot be swapped between columns.
+func (e *Envelope) Encrypt(column, plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	ct, err := seal(e.dek, []byte(plaintext), []byte(column))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ct), nil
+}
+
+func (e *Envelope) Decrypt(column, ciphertext string) (string, error) {
+	if ciphertext == "" {
+		return "", nil
+	}
+	ct, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("pii: %s: %w", column, err)
+	}
+	pt, err := open(e.dek, ct, []byte(column))
+	if err != nil {
+		return "", fmt.Errorf("pii: %s: %w", column, err)
+	}
+	return string(pt), nil
+}
+
+// BlindIndex is a keyed hash of the normalised value, stored next to the
+// ciphertext so equality lookups work without decrypting. nil for empty
+// values or a disabled keyring.
+func (k *Keyring) BlindIndex(column, value string) []byte {
+	value = normalize(column, value)
+	if k == nil || value == "" {
+		return nil
+	}
+	m := hmac.New(sha256.New, k.indexKey)
+	m.Write([]byte(column))
+	m.Write([]byte{0})
+	m.Write([]byte(value))
+	return m.Sum(nil)
+}
+
+// ContentKey keys order content hashes. It is derived from the index key,
+// so one key file still holds every secret. nil for a disabled keyring.
+func (k *Keyring) ContentKey() []byte {
+	if k == nil {
+		return nil
+	}
+	return k.contentKey
+}
+
+// normalize makes "+7 (900) 123-45-67" and "+79001234567" index the same.
+func normalize(column, value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if column != "phone" {
+		return value
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '+' || (r >= '0' && r <= '9') {
+			return r
+		}
+		return -1
+	}, value)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plaintext, ad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, ad), nil
+}
+
+func open(aead cipher.AEAD, ct, ad []byte) ([]byte, error) {
+	if len(ct) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return aead.Open(nil, ct[:aead.NonceSize()], ct[aead.NonceSize():], ad)
+}
+
+func decodeKey(s string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != 32 {
+		return nil, fmt.Errorf("want 32 bytes, got %d", len(b))
+	}
+	return b, nil
+}
@@ -0,0 +1,223 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"order-service/internal/domain/entities"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// SaveBatch stores orders in one transaction with the upsert rules of Save,
+// one multi-row statement per table, so the cost per order is a few rows
+// rather than a few round trips. orders must not repeat an id: a row cannot
+// be upserted twice in one statement.
+func (r *Repository) SaveBatch(ctx context.Context, orders []*entities.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	var (
+		ids                                  = make([]uuid.UUID, len(orders))
+		track, entry, locale, sig, cust, svc []string
+		shard                                []int64
+		sm                                   []int
+
+		dName, dPhone, dZip, dCity, dAddr, dRegion, dEmail []string
+		dKeyID                                             []*string
+		dDEK, dEmailIdx, dPhoneIdx                         [][]byte
+
+		pTx, pReq, pCur, pProv, pBank     []string
+		pAmount, pDt, pCost, pGoods, pFee []int64
+		created                           = make([]time.Time, len(orders))
+		hashes                            = make([][]byte, len(orders))
+
+		iOrder                             []uuid.UUID
+		iChrt, iPrice, iTotal, iNm         []int64
+		iTrack, iRID, iName, iSize, iBrand []string
+		iSale, iStatus                     []int
+	)
+	for i, o := range orders {
+		ids[i] = o.OrderId
+		track = append(track, o.TrackNumber)
+		entry = append(entry, o.Entry)
+		locale = append(locale, o.Locale)
+		sig = append(sig, o.InternalSignature)
+		cust = append(cust, o.CustomerId)
+		svc = append(svc, o.DeliveryService)
+		shard = append(shard, o.ShardKey)
+		sm = append(sm, o.SmId)
+		created[i] = o.DateCreated
+		hashes[i] = o.ContentHash(r.keys.ContentKey())
+
+		d, err := r.seal(o.Delivery)
+		if err != nil {
+			return fmt.Errorf("encrypt delivery of %s: %w", o.OrderId, err)
+		}
+		dName = append(dName, d.name)
+		dPhone = append(dPhone, d.phone)
+		dZip = append(dZip, o.Delivery.Zip)
+		dCity = append(dCity, o.Delivery.City)
+		dAddr = append(dAddr, d.address)
+		dRegion = append(dRegion, o.Delivery.Region)
+		dEmail = append(dEmail, d.email)
+		dKeyID = append(dKeyID, d.keyID)
+		dDEK = append(dDEK, d.dek)
+		dEmailIdx = append(dEmailIdx, d.emailIdx)
+		dPhoneIdx = append(dPhoneIdx, d.phoneIdx)
+
+		p := o.Payment
+		pTx = append(pTx, p.TransactionId)
+		pReq = append(pReq, p.RequestId)
+		pCur = append(pCur, p.Currency)
+		pProv = append(pProv, p.Provider)
+		pAmount = append(pAmount, p.Amount)
+		pDt = append(pDt, p.PaymentDt)
+		pBank = append(pBank, p.Bank)
+		pCost = append(pCost, p.DeliveryCost)
+		pGoods = append(pGoods, p.GoodsTotal)
+		pFee = append(pFee, p.CustomFee)
+
+		for _, it := range o.Items {
+			iOrder = append(iOrder, o.OrderId)
+			iChrt = append(iChrt, it.ChrtId)
+			iTrack = append(iTrack, it.TrackNumber)
+			iPrice = append(iPrice, it.Price)
+			iRID = append(iRID, it.RID)
+			iName = append(iName, it.Name)
+			iSale = append(iSale, it.Sale)
+			iSize = append(iSize, it.Size)
+			iTotal = append(iTotal, it.TotalPrice)
+			iNm = append(iNm, it.NmID)
+			iBrand = append(iBrand, it.Brand)
+			iStatus = append(iStatus, it.Status)
+		}
+	}
+
+	const q1 = `INSERT INTO orders(
+	               order_uid, track_number, entry, locale, internal_signature,
+	               customer_id, delivery_service, shardkey, sm_id, date_created, content_hash
+	           )
+	           SELECT * FROM unnest($1::uuid[], $2::text[], $3::text[], $4::text[], $5::text[],
+	                                $6::text[], $7::text[], $8::int8[], $9::int4[], $10::timestamptz[], $11::bytea[])
+	           ON CONFLICT (order_uid) DO UPDATE SET
+	               track_number       = EXCLUDED.track_number,
+	               entry              = EXCLUDED.entry,
+	               locale             = EXCLUDED.locale,
+	               internal_signature = EXCLUDED.internal_signature,
+	               customer_id        = EXCLUDED.customer_id,
+	               delivery_service   = EXCLUDED.delivery_service,
+	               shardkey           = EXCLUDED.shardkey,
+	               sm_id              = EXCLUDED.sm_id,
+	               content_hash       = EXCLUDED.content_hash`
+	const q2 = `INSERT INTO deliveries(
+	               order_uid, del_name, phone, zip, city, address, region, email,
+	               key_id, dek, email_bidx, phone_bidx
+	           )
+	           SELECT * FROM unnest($1::uuid[], $2::text[], $3::text[], $4::text[], $5::text[], $6::text[],
+	                                $7::text[], $8::text[], $9::text[], $10::bytea[], $11::bytea[], $12::bytea[])
+	           ON CONFLICT (order_uid) DO UPDATE SET
+	               del_name   = EXCLUDED.del_name,
+	               phone      = EXCLUDED.phone,
+	               zip        = EXCLUDED.zip,
+	               city       = EXCLUDED.city,
+	               address    = EXCLUDED.address,
+	               region     = EXCLUDED.region,
+	               email      = EXCLUDED.email,
+	               key_id     = EXCLUDED.key_id,
+	               dek        = EXCLUDED.dek,
+	               email_bidx = EXCLUDED.email_bidx,
+	               phone_bidx = EXCLUDED.phone_bidx`
+	const q3 = `INSERT INTO payments(
+	               order_uid, transaction_id, request_id, currency, provider,
+	               amount, payment_dt, bank, delivery_cost, goods_total, custom_fee
+	           )
+	           SELECT * FROM unnest($1::uuid[], $2::text[], $3::text[], $4::text[], $5::text[],
+	                                $6::numeric[], $7::int8[], $8::text[], $9::numeric[], $10::numeric[], $11::numeric[])
+	           ON CONFLICT (order_uid) DO UPDATE SET
+	               transaction_id = EXCLUDED.transaction_id,
+	               request_id     = EXCLUDED.request_id,
+	               currency       = EXCLUDED.currency,
+	               provider       = EXCLUDED.provider,
+	               amount         = EXCLUDED.amount,
+	               payment_dt     = EXCLUDED.payment_dt,
+	               bank           = EXCLUDED.bank,
+	               delivery_cost  = EXCLUDED.delivery_cost,
+	               goods_total    = EXCLUDED.goods_total,
+	               custom_fee     = EXCLUDED.custom_fee`
+	const q4 = `INSERT INTO items(
+	               order_uid, chrt_id, track_number, price, rid, item_name, sale, item_size, total_price, nm_id, brand, status
+	           )
+	           SELECT * FROM unnest($1::uuid[], $2::int8[], $3::text[], $4::numeric[], $5::text[], $6::text[],
+	                                $7::int4[], $8::text[], $9::numeric[], $10::int8[], $11::text[], $12::int4[])`
+
+	// all statements go out in one round trip; a failing one aborts the
+	// transaction and with it the rest
+	b := &pgx.Batch{}
+	b.Queue(q1, ids, track, entry, locale, sig, cust, svc, shard, sm, created, hashes)
+	b.Queue(q2, ids, dName, dPhone, dZip, dCity, dAddr, dRegion, dEmail, dKeyID, dDEK, dEmailIdx, dPhoneIdx)
+	b.Queue(q3, ids, pTx, pReq, pCur, pProv, pAmount, pDt, pBank, pCost, pGoods, pFee)
+	b.Queue(`DELETE FROM items WHERE order_uid = ANY($1)`, ids)
+	if len(iOrder) > 0 {
+		b.Queue(q4, iOrder, iChrt, iTrack, iPrice, iRID, iName, iSale, iSize, iTotal, iNm, iBrand, iStatus)
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if err := tx.SendBatch(ctx, b).Close(); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	r.logger(ctx).Infow("orders saved", "orders", len(orders), "items", len(iOrder))
+	return nil
+}
+
+// Unchanged reports which of orders are stored with the same content
+// already, so saving them again would change nothing.
+func (r *Repository) Unchanged(ctx context.Context, orders []*entities.Order) (map[uuid.UUID]bool, error) {
+	ids := make([]uuid.UUID, len(orders))
+	for i, o := range orders {
+		ids[i] = o.OrderId
+	}
+	rows, err := r.pool.Query(ctx,
+		`SELECT order_uid, content_hash FROM orders WHERE order_uid = ANY($1) AND content_hash IS NOT NULL`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stored := make(map[uuid.UUID][]byte, len(ids))
+	for rows.Next() {
+		var (
+			id uuid.UUID
+			h  []byte
+		)
+		if err := rows.Scan(&id, &h); err != nil {
+			return nil, err
+		}
+		stored[id] = h
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return r.sameContent(orders, stored), nil
+}
+
+func (r *Repository) sameContent(orders []*entities.Order, stored map[uuid.UUID][]byte) map[uuid.UUID]bool {
+	out := make(map[uuid.UUID]bool)
+	for _, o := range orders {
+		if h, ok := stored[o.OrderId]; ok && bytes.Equal(h, o.ContentHash(r.keys.ContentKey())) {
+			out[o.OrderId] = true
+		}
+	}
+	return out
+}
@@ -0,0 +1,182 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"order-service/internal/domain/entities"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Encrypted delivery columns. The names double as associated data, so a
+// ciphertext only decrypts in the column it was written to.
+const (
+	colName    = "del_name"
+	colPhone   = "phone"
+	colAddress = "address"
+	colEmail   = "email"
+)
+
+// sealedDelivery is a delivery as it goes into the deliveries table.
+type sealedDelivery struct {
+	name, phone, address, email string
+	keyID                       *string
+	dek                         []byte
+	emailIdx, phoneIdx          []byte
+}
+
+// seal encrypts the PII columns of d under a fresh data key. Without a
+// keyring the values pass through and key_id stays NULL.
+func (r *Repository) seal(d entities.Delivery) (sealedDelivery, error) {
+	if !r.keys.Enabled() {
+		return sealedDelivery{name: d.Name, phone: d.Phone, address: d.Address, email: d.Email}, nil
+	}
+	env, err := r.keys.NewEnvelope()
+	if err != nil {
+		return sealedDelivery{}, err
+	}
+	s := sealedDelivery{
+		keyID:    &env.KeyID,
+		dek:      env.WrappedDEK,
+		emailIdx: r.keys.BlindIndex(colEmail, d.Email),
+		phoneIdx: r.keys.BlindIndex(colPhone, d.Phone),
+	}
+	for _, f := range []struct {
+		col string
+		in  string
+		out *string
+	}{
+		{colName, d.Name, &s.name},
+		{colPhone, d.Phone, &s.phone},
+		{colAddress, d.Address, &s.address},
+		{colEmail, d.Email, &s.email},
+	} {
+		if *f.out, err = env.Encrypt(f.col, f.in); err != nil {
+			return sealedDelivery{}, err
+		}
+	}
+	return s, nil
+}
+
+// open decrypts the PII columns of d in place. Rows with a NULL key_id
+// were written before encryption was enabled and are left as they are.
+func (r *Repository) open(d *entities.Delivery, keyID *string, dek []byte) error {
+	if keyID == nil {
+		return nil
+	}
+	if !r.keys.Enabled() {
+		return errors.New("delivery is encrypted but PII_KEY_FILE is not set")
+	}
+	env, err := r.keys.OpenEnvelope(*keyID, dek)
+	if err != nil {
+		return err
+	}
+	for _, f := range []struct {
+		col string
+		v   *string
+	}{
+		{colName, &d.Name},
+		{colPhone, &d.Phone},
+		{colAddress, &d.Address},
+		{colEmail, &d.Email},
+	} {
+		if *f.v, err = env.Decrypt(f.col, *f.v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RotateKeys re-encrypts deliveries not yet under the active key, batch
+// rows per transaction, and returns how many rows it rewrote. Plaintext
+// rows from before encryption are picked up too. Safe to run next to the
+// service: rows are locked with SKIP LOCKED and Save always writes with
+// the active key.
+func (r *Repository) RotateKeys(ctx context.Context, batch int) (int, error) {
+	if !r.keys.Enabled() {
+		return 0, errors.New("rotate keys: PII_KEY_FILE is not set")
+	}
+	if batch <= 0 {
+		batch = 500
+	}
+	active := r.keys.ActiveKey()
+	total := 0
+	for {
+		n, err := r.rotateBatch(ctx, active, batch)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n == 0 {
+			return total, nil
+		}
+		r.logger(ctx).Infow("rotated batch", "rows", n, "total", total, "key_id", active)
+	}
+}
+
+func (r *Repository) rotateBatch(ctx context.Context, active string, batch int) (int, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	const sel = `SELECT order_uid, del_name, phone, address, email, key_id, dek
+	             FROM deliveries
+	             WHERE key_id IS DISTINCT FROM $1
+	             ORDER BY order_uid
+	             LIMIT $2
+	             FOR UPDATE SKIP LOCKED`
+	rows, err := tx.Query(ctx, sel, active, batch)
+	if err != nil {
+		return 0, err
+	}
+	type row struct {
+		id    uuid.UUID
+		d     entities.Delivery
+		keyID *string
+		dek   []byte
+	}
+	var todo []row
+	for rows.Next() {
+		var x row
+		if err := rows.Scan(&x.id, &x.d.Name, &x.d.Phone, &x.d.Address, &x.d.Email, &x.keyID, &x.dek); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		todo = append(todo, x)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(todo) == 0 {
+		return 0, nil
+	}
+
+	const upd = `UPDATE deliveries
+	             SET del_name = $2, phone = $3, address = $4, email = $5,
+	                 key_id = $6, dek = $7, email_bidx = $8, phone_bidx = $9
+	             WHERE order_uid = $1`
+	b := &pgx.Batch{}
+	for _, x := range todo {
+		if err := r.open(&x.d, x.keyID, x.dek); err != nil {
+			return 0, fmt.Errorf("order %s: %w", x.id, err)
+		}
+		s, err := r.seal(x.d)
+		if err != nil {
+			return 0, fmt.Errorf("order %s: %w", x.id, err)
+		}
+		b.Queue(upd, x.id, s.name, s.phone, s.address, s.email, s.keyID, s.dek, s.emailIdx, s.phoneIdx)
+	}
+	if err := tx.SendBatch(ctx, b).Close(); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return len(todo), nil
+}
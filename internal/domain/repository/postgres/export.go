@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"order-service/internal/domain/entities"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const exportFetch = 500
+
+// Export calls fn for every order matching f, oldest first, with delivery,
+// payment and items filled in. Rows come from a server-side cursor, fetched
+// exportFetch at a time inside one read-only snapshot, so memory does not
+// grow with the size of the range. A non-nil error from fn stops the export
+// and is returned as is.
+func (r *Repository) Export(ctx context.Context, f entities.OrderFilter, fn func(*entities.Order) error) error {
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	// deliveries and payments are written in the same transaction as the
+	// order, so inner joins lose nothing
+	q := `DECLARE export_orders NO SCROLL CURSOR FOR
+	      SELECT o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, o.customer_id,
+	             o.delivery_service, o.shardkey, o.sm_id, o.date_created,
+	             d.del_name, d.phone, d.zip, d.city, d.address, d.region, d.email, d.key_id, d.dek,
+	             p.transaction_id, p.request_id, p.currency, p.provider, p.amount::BIGINT, p.payment_dt,
+	             p.bank, p.delivery_cost::BIGINT, p.goods_total::BIGINT, p.custom_fee::BIGINT
+	      FROM orders o
+	      JOIN deliveries d ON d.order_uid = o.order_uid
+	      JOIN payments p ON p.order_uid = o.order_uid`
+	if where := r.filterWhere(f, arg); len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
+	}
+	q += " ORDER BY o.date_created, o.order_uid"
+	if _, err := tx.Exec(ctx, q, args...); err != nil {
+		r.logger(ctx).Errorw("declare export cursor failed", "error", err)
+		return err
+	}
+
+	total := 0
+	for {
+		batch, err := r.fetchExport(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		ids := make([]uuid.UUID, len(batch))
+		for i, o := range batch {
+			ids[i] = o.OrderId
+		}
+		items, err := r.itemsByOrders(ctx, tx, ids)
+		if err != nil {
+			return err
+		}
+		for _, o := range batch {
+			o.Items = items[o.OrderId]
+			if o.Items == nil {
+				o.Items = []entities.Item{}
+			}
+			if err := fn(o); err != nil {
+				return err
+			}
+		}
+		total += len(batch)
+	}
+	r.logger(ctx).Infow("export done", "orders", total)
+	return nil
+}
+
+func (r *Repository) fetchExport(ctx context.Context, tx pgx.Tx) ([]*entities.Order, error) {
+	rows, err := tx.Query(ctx, fmt.Sprintf("FETCH %d FROM export_orders", exportFetch))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*entities.Order
+	for rows.Next() {
+		var (
+			o     entities.Order
+			keyID *string
+			dek   []byte
+		)
+		if err := rows.Scan(
+			&o.OrderId, &o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSignature, &o.CustomerId,
+			&o.DeliveryService, &o.ShardKey, &o.SmId, &o.DateCreated,
+			&o.Delivery.Name, &o.Delivery.Phone, &o.Delivery.Zip, &o.Delivery.City, &o.Delivery.Address,
+			&o.Delivery.Region, &o.Delivery.Email, &keyID, &dek,
+			&o.Payment.TransactionId, &o.Payment.RequestId, &o.Payment.Currency, &o.Payment.Provider,
+			&o.Payment.Amount, &o.Payment.PaymentDt, &o.Payment.Bank, &o.Payment.DeliveryCost,
+			&o.Payment.GoodsTotal, &o.Payment.CustomFee,
+		); err != nil {
+			return nil, err
+		}
+		if err := r.open(&o.Delivery, keyID, dek); err != nil {
+			return nil, fmt.Errorf("order %s: %w", o.OrderId, err)
+		}
+		out = append(out, &o)
+	}
+	return out, rows.Err()
+}
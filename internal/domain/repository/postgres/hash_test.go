@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"order-service/internal/domain/entities"
+	"order-service/internal/domain/repository/pii"
+
+	"github.com/google/uuid"
+)
+
+func testKeyring(t *testing.T) *pii.Keyring {
+	t.Helper()
+	key := func() string {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			t.Fatal(err)
+		}
+		return base64.StdEncoding.EncodeToString(b)
+	}
+	b, _ := json.Marshal(map[string]any{"active": "k1", "keys": map[string]string{"k1": key()}, "index_key": key()})
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	k, err := pii.LoadKeyring(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func hashTestOrder() *entities.Order {
+	return &entities.Order{
+		OrderId:     uuid.MustParse("b563feb7-b2b8-4b6c-9f5d-000000000001"),
+		TrackNumber: "WBILMTESTTRACK",
+		CustomerId:  "test",
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		Delivery:    entities.Delivery{Name: "Test Testov", Phone: "+9720000000", Email: "test@gmail.com"},
+		Payment:     entities.Payment{TransactionId: "b563feb7b2b84b6ctest", Currency: "USD", Amount: 1817},
+		Items:       []entities.Item{{ChrtId: 9934930, Price: 453, Name: "Mascaras"}},
+	}
+}
+
+// replay decodes o from differently formatted JSON, as a redelivered
+// message would be.
+func replay(t *testing.T, o *entities.Order) *entities.Order {
+	t.Helper()
+	b, err := json.MarshalIndent(o, "", "\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out entities.Order
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	return &out
+}
+
+func TestReplayDetected(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		keys *pii.Keyring
+	}{
+		{"keyed", testKeyring(t)},
+		{"plaintext", nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Repository{keys: tt.keys}
+			saved := hashTestOrder()
+			stored := map[uuid.UUID][]byte{saved.OrderId: saved.ContentHash(r.keys.ContentKey())}
+
+			if got := r.sameContent([]*entities.Order{replay(t, saved)}, stored); !got[saved.OrderId] {
+				t.Error("replayed order not detected")
+			}
+
+			changed := replay(t, saved)
+			changed.Delivery.Phone = "+9720000001"
+			other := hashTestOrder()
+			other.OrderId = uuid.MustParse("b563feb7-b2b8-4b6c-9f5d-000000000002")
+			if got := r.sameContent([]*entities.Order{changed, other}, stored); len(got) != 0 {
+				t.Errorf("changed or new orders taken for replays: %v", got)
+			}
+		})
+	}
+}
+
+func TestContentHashKeyed(t *testing.T) {
+	o := hashTestOrder()
+	b, _ := json.Marshal(o)
+	plain := sha256.Sum256(b)
+
+	k1, k2 := testKeyring(t), testKeyring(t)
+	h1 := o.ContentHash(k1.ContentKey())
+	if string(h1) == string(plain[:]) {
+		t.Fatal("keyed hash equals the unkeyed SHA-256, PII can be checked offline")
+	}
+	if string(h1) == string(o.ContentHash(k2.ContentKey())) {
+		t.Error("different key files give the same hash")
+	}
+}
@@ -0,0 +1,169 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"order-service/internal/domain/entities"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ImportProgress returns the last line of source already imported, 0 if
+// none.
+func (r *Repository) ImportProgress(ctx context.Context, source string) (int64, error) {
+	var line int64
+	err := r.pool.QueryRow(ctx, `SELECT line FROM import_progress WHERE source = $1`, source).Scan(&line)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return line, err
+}
+
+// ImportBatch COPYs orders into temporary staging tables and merges them
+// into the real ones with the same upsert rules as Save, then records line
+// as the progress of source. All of it is one transaction, so a batch is
+// either imported and counted or neither. orders must not repeat an id.
+func (r *Repository) ImportBatch(ctx context.Context, source string, line int64, orders []*entities.Order) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	const stage = `
+	CREATE TEMP TABLE stage_orders     (LIKE orders)     ON COMMIT DROP;
+	CREATE TEMP TABLE stage_deliveries (LIKE deliveries) ON COMMIT DROP;
+	CREATE TEMP TABLE stage_payments   (LIKE payments)   ON COMMIT DROP;
+	CREATE TEMP TABLE stage_items      (LIKE items EXCLUDING DEFAULTS) ON COMMIT DROP;
+	ALTER TABLE stage_items DROP COLUMN item_id;`
+	if _, err := tx.Exec(ctx, stage); err != nil {
+		return fmt.Errorf("create staging tables: %w", err)
+	}
+
+	var (
+		ords, dels, pays, items [][]any
+	)
+	for _, o := range orders {
+		ords = append(ords, []any{
+			o.OrderId, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature,
+			o.CustomerId, o.DeliveryService, o.ShardKey, o.SmId, o.DateCreated, o.ContentHash(r.keys.ContentKey()),
+		})
+		d, err := r.seal(o.Delivery)
+		if err != nil {
+			return fmt.Errorf("encrypt delivery of %s: %w", o.OrderId, err)
+		}
+		dels = append(dels, []any{
+			o.OrderId, d.name, d.phone, o.Delivery.Zip, o.Delivery.City, d.address, o.Delivery.Region, d.email,
+			d.keyID, d.dek, d.emailIdx, d.phoneIdx,
+		})
+		pays = append(pays, []any{
+			o.OrderId, o.Payment.TransactionId, o.Payment.RequestId, o.Payment.Currency, o.Payment.Provider,
+			o.Payment.Amount, o.Payment.PaymentDt, o.Payment.Bank, o.Payment.DeliveryCost, o.Payment.GoodsTotal, o.Payment.CustomFee,
+		})
+		for _, it := range o.Items {
+			items = append(items, []any{
+				o.OrderId, it.ChrtId, it.TrackNumber, it.Price, it.RID, it.Name, it.Sale, it.Size, it.TotalPrice, it.NmID, it.Brand, it.Status,
+			})
+		}
+	}
+
+	copies := []struct {
+		table string
+		cols  []string
+		rows  [][]any
+	}{
+		{"stage_orders", []string{"order_uid", "track_number", "entry", "locale", "internal_signature",
+			"customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "content_hash"}, ords},
+		{"stage_deliveries", []string{"order_uid", "del_name", "phone", "zip", "city", "address", "region", "email",
+			"key_id", "dek", "email_bidx", "phone_bidx"}, dels},
+		{"stage_payments", []string{"order_uid", "transaction_id", "request_id", "currency", "provider",
+			"amount", "payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee"}, pays},
+		{"stage_items", []string{"order_uid", "chrt_id", "track_number", "price", "rid", "item_name", "sale",
+			"item_size", "total_price", "nm_id", "brand", "status"}, items},
+	}
+	for _, c := range copies {
+		if len(c.rows) == 0 {
+			continue
+		}
+		if _, err := tx.CopyFrom(ctx, pgx.Identifier{c.table}, c.cols, pgx.CopyFromRows(c.rows)); err != nil {
+			return fmt.Errorf("copy %s: %w", c.table, err)
+		}
+	}
+
+	const merge = `
+	INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature,
+	                    customer_id, delivery_service, shardkey, sm_id, date_created, content_hash)
+	SELECT order_uid, track_number, entry, locale, internal_signature,
+	       customer_id, delivery_service, shardkey, sm_id, date_created, content_hash
+	FROM stage_orders
+	ON CONFLICT (order_uid) DO UPDATE SET
+	    track_number       = EXCLUDED.track_number,
+	    entry              = EXCLUDED.entry,
+	    locale             = EXCLUDED.locale,
+	    internal_signature = EXCLUDED.internal_signature,
+	    customer_id        = EXCLUDED.customer_id,
+	    delivery_service   = EXCLUDED.delivery_service,
+	    shardkey           = EXCLUDED.shardkey,
+	    sm_id              = EXCLUDED.sm_id,
+	    content_hash       = EXCLUDED.content_hash;
+
+	INSERT INTO deliveries (order_uid, del_name, phone, zip, city, address, region, email,
+	                        key_id, dek, email_bidx, phone_bidx)
+	SELECT order_uid, del_name, phone, zip, city, address, region, email,
+	       key_id, dek, email_bidx, phone_bidx
+	FROM stage_deliveries
+	ON CONFLICT (order_uid) DO UPDATE SET
+	    del_name   = EXCLUDED.del_name,
+	    phone      = EXCLUDED.phone,
+	    zip        = EXCLUDED.zip,
+	    city       = EXCLUDED.city,
+	    address    = EXCLUDED.address,
+	    region     = EXCLUDED.region,
+	    email      = EXCLUDED.email,
+	    key_id     = EXCLUDED.key_id,
+	    dek        = EXCLUDED.dek,
+	    email_bidx = EXCLUDED.email_bidx,
+	    phone_bidx = EXCLUDED.phone_bidx;
+
+	INSERT INTO payments (order_uid, transaction_id, request_id, currency, provider,
+	                      amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
+	SELECT order_uid, transaction_id, request_id, currency, provider,
+	       amount, payment_dt, bank, delivery_cost, goods_total, custom_fee
+	FROM stage_payments
+	ON CONFLICT (order_uid) DO UPDATE SET
+	    transaction_id = EXCLUDED.transaction_id,
+	    request_id     = EXCLUDED.request_id,
+	    currency       = EXCLUDED.currency,
+	    provider       = EXCLUDED.provider,
+	    amount         = EXCLUDED.amount,
+	    payment_dt     = EXCLUDED.payment_dt,
+	    bank           = EXCLUDED.bank,
+	    delivery_cost  = EXCLUDED.delivery_cost,
+	    goods_total    = EXCLUDED.goods_total,
+	    custom_fee     = EXCLUDED.custom_fee;
+
+	DELETE FROM items WHERE order_uid IN (SELECT order_uid FROM stage_orders);
+
+	INSERT INTO items (order_uid, chrt_id, track_number, price, rid, item_name, sale,
+	                   item_size, total_price, nm_id, brand, status)
+	SELECT order_uid, chrt_id, track_number, price, rid, item_name, sale,
+	       item_size, total_price, nm_id, brand, status
+	FROM stage_items;`
+	if _, err := tx.Exec(ctx, merge); err != nil {
+		return fmt.Errorf("merge: %w", err)
+	}
+
+	const progress = `INSERT INTO import_progress (source, line) VALUES ($1, $2)
+	                  ON CONFLICT (source) DO UPDATE SET line = EXCLUDED.line, updated_at = now()`
+	if _, err := tx.Exec(ctx, progress, source, line); err != nil {
+		return fmt.Errorf("record progress: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	r.logger(ctx).Infow("import batch merged", "source", source, "line", line, "orders", len(orders), "items", len(items))
+	return nil
+}
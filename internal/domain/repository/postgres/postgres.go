@@ -6,6 +6,8 @@ import (
 
 	"order-service/config"
 	"order-service/internal/domain/entities"
+	"order-service/internal/domain/logctx"
+	"order-service/internal/domain/repository/pii"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
@@ -19,15 +21,16 @@ import (
 type Repository struct {
 	pool *pgxpool.Pool
 	cfg  *config.ConfigModel
+	keys *pii.Keyring
 	log  *zap.SugaredLogger
 }
 
-func NewRepository(ctx context.Context, cfg *config.ConfigModel, l *zap.Logger) (*Repository, error) {
+func NewRepository(ctx context.Context, cfg *config.ConfigModel, keys *pii.Keyring, l *zap.Logger) (*Repository, error) {
 	pool, err := pgxpool.New(ctx, cfg.Postgres.DSN)
 	if err != nil {
 		return nil, err
 	}
-	r := &Repository{pool: pool, cfg: cfg, log: l.Named("postgres").Sugar()}
+	r := &Repository{pool: pool, cfg: cfg, keys: keys, log: l.Named("postgres").Sugar()}
 
 	if err := r.runMigrations(); err != nil {
 		pool.Close()
@@ -51,15 +54,20 @@ func (r *Repository) runMigrations() error {
 
 func (r *Repository) Close() { r.pool.Close() }
 
+// logger tags lines with the request id from ctx.
+func (r *Repository) logger(ctx context.Context) *zap.SugaredLogger { return logctx.From(ctx, r.log) }
+
+func (r *Repository) Ping(ctx context.Context) error { return r.pool.Ping(ctx) }
+
 func (r *Repository) Find(ctx context.Context, id string) (*entities.Order, error) {
 	var ord entities.Order
 
 	u, err := uuid.Parse(id)
 	if err != nil {
-		r.log.Warnw("invalid uuid", "order_uid", id, "error", err)
+		r.logger(ctx).Warnw("invalid uuid", "order_uid", id, "error", err)
 		return nil, fmt.Errorf("invalid uuid: %w", err)
 	}
-	r.log.Debugw("db find", "order_uid", u)
+	r.logger(ctx).Debugw("db find", "order_uid", u)
 
 	const orderSQL = `SELECT order_uid, track_number, entry, locale, internal_signature, customer_id,
 	                  delivery_service, shardkey, sm_id, date_created
@@ -70,20 +78,28 @@ func (r *Repository) Find(ctx context.Context, id string) (*entities.Order, erro
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			r.log.Infow("not found", "order_uid", u)
+			r.logger(ctx).Infow("not found", "order_uid", u)
 			return nil, nil
 		}
-		r.log.Errorw("query order failed", "order_uid", u, "error", err)
+		r.logger(ctx).Errorw("query order failed", "order_uid", u, "error", err)
 		return nil, err
 	}
 
-	const delSQL = `SELECT del_name, phone, zip, city, address, region, email
+	const delSQL = `SELECT del_name, phone, zip, city, address, region, email, key_id, dek
 	                FROM deliveries WHERE order_uid=$1`
+	var (
+		keyID *string
+		dek   []byte
+	)
 	if err = r.pool.QueryRow(ctx, delSQL, u).Scan(
 		&ord.Delivery.Name, &ord.Delivery.Phone, &ord.Delivery.Zip, &ord.Delivery.City,
-		&ord.Delivery.Address, &ord.Delivery.Region, &ord.Delivery.Email,
+		&ord.Delivery.Address, &ord.Delivery.Region, &ord.Delivery.Email, &keyID, &dek,
 	); err != nil {
-		r.log.Errorw("query delivery failed", "order_uid", u, "error", err)
+		r.logger(ctx).Errorw("query delivery failed", "order_uid", u, "error", err)
+		return nil, err
+	}
+	if err = r.open(&ord.Delivery, keyID, dek); err != nil {
+		r.logger(ctx).Errorw("decrypt delivery failed", "order_uid", u, "error", err)
 		return nil, err
 	}
 
@@ -95,7 +111,7 @@ func (r *Repository) Find(ctx context.Context, id string) (*entities.Order, erro
 		&ord.Payment.Amount, &ord.Payment.PaymentDt, &ord.Payment.Bank, &ord.Payment.DeliveryCost,
 		&ord.Payment.GoodsTotal, &ord.Payment.CustomFee,
 	); err != nil {
-		r.log.Errorw("query payment failed", "order_uid", u, "error", err)
+		r.logger(ctx).Errorw("query payment failed", "order_uid", u, "error", err)
 		return nil, err
 	}
 
@@ -103,7 +119,7 @@ func (r *Repository) Find(ctx context.Context, id string) (*entities.Order, erro
 	                 FROM items WHERE order_uid=$1`
 	rows, err := r.pool.Query(ctx, itemSQL, u)
 	if err != nil {
-		r.log.Errorw("query items failed", "order_uid", u, "error", err)
+		r.logger(ctx).Errorw("query items failed", "order_uid", u, "error", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -112,12 +128,12 @@ func (r *Repository) Find(ctx context.Context, id string) (*entities.Order, erro
 	for rows.Next() {
 		var it entities.Item
 		if err := rows.Scan(&it.ChrtId, &it.TrackNumber, &it.Price, &it.RID, &it.Name, &it.Sale, &it.Size, &it.TotalPrice, &it.NmID, &it.Brand, &it.Status); err != nil {
-			r.log.Errorw("scan item failed", "order_uid", u, "error", err)
+			r.logger(ctx).Errorw("scan item failed", "order_uid", u, "error", err)
 			return nil, err
 		}
 		ord.Items = append(ord.Items, it)
 	}
-	r.log.Infow("order loaded", "order_uid", u, "items", len(ord.Items))
+	r.logger(ctx).Infow("order loaded", "order_uid", u, "items", len(ord.Items))
 	return &ord, nil
 }
 
@@ -130,9 +146,9 @@ func (r *Repository) Save(ctx context.Context, o *entities.Order) error {
 
 	const q1 = `INSERT INTO orders(
 	               order_uid, track_number, entry, locale, internal_signature,
-	               customer_id, delivery_service, shardkey, sm_id, date_created
+	               customer_id, delivery_service, shardkey, sm_id, date_created, content_hash
 	           )
-	           VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
+	           VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
 	           ON CONFLICT (order_uid) DO UPDATE SET
 	               track_number       = EXCLUDED.track_number,
 	               entry              = EXCLUDED.entry,
@@ -141,29 +157,40 @@ func (r *Repository) Save(ctx context.Context, o *entities.Order) error {
 	               customer_id        = EXCLUDED.customer_id,
 	               delivery_service   = EXCLUDED.delivery_service,
 	               shardkey           = EXCLUDED.shardkey,
-	               sm_id              = EXCLUDED.sm_id`
+	               sm_id              = EXCLUDED.sm_id,
+	               content_hash       = EXCLUDED.content_hash`
 	if _, err := tx.Exec(ctx, q1,
 		o.OrderId, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature,
-		o.CustomerId, o.DeliveryService, o.ShardKey, o.SmId, o.DateCreated,
+		o.CustomerId, o.DeliveryService, o.ShardKey, o.SmId, o.DateCreated, o.ContentHash(r.keys.ContentKey()),
 	); err != nil {
 		return err
 	}
 
+	del, err := r.seal(o.Delivery)
+	if err != nil {
+		return fmt.Errorf("encrypt delivery: %w", err)
+	}
 	const q2 = `INSERT INTO deliveries(
-	               order_uid, del_name, phone, zip, city, address, region, email
+	               order_uid, del_name, phone, zip, city, address, region, email,
+	               key_id, dek, email_bidx, phone_bidx
 	           )
-	           VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+	           VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
 	           ON CONFLICT (order_uid) DO UPDATE SET
-	               del_name = EXCLUDED.del_name,
-	               phone    = EXCLUDED.phone,
-	               zip      = EXCLUDED.zip,
-	               city     = EXCLUDED.city,
-	               address  = EXCLUDED.address,
-	               region   = EXCLUDED.region,
-	               email    = EXCLUDED.email`
+	               del_name   = EXCLUDED.del_name,
+	               phone      = EXCLUDED.phone,
+	               zip        = EXCLUDED.zip,
+	               city       = EXCLUDED.city,
+	               address    = EXCLUDED.address,
+	               region     = EXCLUDED.region,
+	               email      = EXCLUDED.email,
+	               key_id     = EXCLUDED.key_id,
+	               dek        = EXCLUDED.dek,
+	               email_bidx = EXCLUDED.email_bidx,
+	               phone_bidx = EXCLUDED.phone_bidx`
 	if _, err := tx.Exec(ctx, q2,
-		o.OrderId, o.Delivery.Name, o.Delivery.Phone, o.Delivery.Zip, o.Delivery.City,
-		o.Delivery.Address, o.Delivery.Region, o.Delivery.Email,
+		o.OrderId, del.name, del.phone, o.Delivery.Zip, o.Delivery.City,
+		del.address, o.Delivery.Region, del.email,
+		del.keyID, del.dek, del.emailIdx, del.phoneIdx,
 	); err != nil {
 		return err
 	}
@@ -210,7 +237,7 @@ func (r *Repository) Save(ctx context.Context, o *entities.Order) error {
 	if err := tx.Commit(ctx); err != nil {
 		return err
 	}
-	r.log.Infow("order saved", "order_uid", o.OrderId)
+	r.logger(ctx).Infow("order saved", "order_uid", o.OrderId)
 	return nil
 }
 
@@ -0,0 +1,263 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"order-service/internal/domain/entities"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ListIDs returns ids of orders matching f, newest first, starting after
+// f.After.
+func (r *Repository) ListIDs(ctx context.Context, f entities.OrderFilter) ([]uuid.UUID, error) {
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	where := r.filterWhere(f, arg)
+	if f.After != nil {
+		where = append(where, fmt.Sprintf("(o.date_created, o.order_uid) < (%s, %s)",
+			arg(f.After.DateCreated), arg(f.After.OrderId)))
+	}
+
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+
+	q := `SELECT o.order_uid FROM orders o
+	      LEFT JOIN deliveries d ON d.order_uid = o.order_uid
+	      LEFT JOIN payments p ON p.order_uid = o.order_uid`
+	if len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
+	}
+	q += " ORDER BY o.date_created DESC, o.order_uid DESC LIMIT " + arg(limit)
+
+	rows, err := r.pool.Query(ctx, q, args...)
+	if err != nil {
+		r.logger(ctx).Errorw("list orders failed", "error", err)
+		return nil, err
+	}
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	return ids, rows.Err()
+}
+
+// filterWhere turns f, except paging, into conditions on orders o,
+// deliveries d and payments p; arg binds a value and returns its
+// placeholder.
+func (r *Repository) filterWhere(f entities.OrderFilter, arg func(any) string) []string {
+	var where []string
+	if f.CustomerId != "" {
+		where = append(where, "o.customer_id = "+arg(f.CustomerId))
+	}
+	if f.DeliveryService != "" {
+		where = append(where, "o.delivery_service = "+arg(f.DeliveryService))
+	}
+	if f.TrackNumber != "" {
+		where = append(where, "o.track_number = "+arg(f.TrackNumber))
+	}
+	if f.Email != "" {
+		where = append(where, r.piiEquals("email", f.Email, arg))
+	}
+	if f.Phone != "" {
+		where = append(where, r.piiEquals("phone", f.Phone, arg))
+	}
+	if f.MinAmount > 0 {
+		where = append(where, "p.amount >= "+arg(f.MinAmount))
+	}
+	if !f.From.IsZero() {
+		where = append(where, "o.date_created >= "+arg(f.From))
+	}
+	if !f.To.IsZero() {
+		where = append(where, "o.date_created < "+arg(f.To))
+	}
+	return where
+}
+
+// piiEquals matches an encrypted column through its blind index. Rows
+// written before encryption was enabled are still compared in plaintext.
+func (r *Repository) piiEquals(col, v string, arg func(any) string) string {
+	if !r.keys.Enabled() {
+		return fmt.Sprintf("d.%s = %s", col, arg(v))
+	}
+	return fmt.Sprintf("(d.%s_bidx = %s OR (d.key_id IS NULL AND d.%s = %s))",
+		col, arg(r.keys.BlindIndex(col, v)), col, arg(v))
+}
+
+// FindMany loads full orders for ids with one query per table. The result
+// keeps the order of ids; unknown ids are skipped.
+func (r *Repository) FindMany(ctx context.Context, ids []uuid.UUID) ([]*entities.Order, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	orders, err := r.OrdersByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	dels, err := r.DeliveriesByOrders(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	pays, err := r.PaymentsByOrders(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	items, err := r.ItemsByOrders(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*entities.Order, 0, len(orders))
+	for _, id := range ids {
+		o, ok := orders[id]
+		if !ok {
+			continue
+		}
+		if d, ok := dels[id]; ok {
+			o.Delivery = *d
+		}
+		if p, ok := pays[id]; ok {
+			o.Payment = *p
+		}
+		o.Items = items[id]
+		if o.Items == nil {
+			o.Items = []entities.Item{}
+		}
+		out = append(out, o)
+	}
+	r.logger(ctx).Debugw("orders loaded", "requested", len(ids), "found", len(out))
+	return out, nil
+}
+
+// OrdersByIDs loads order headers only, without delivery, payment and items.
+func (r *Repository) OrdersByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*entities.Order, error) {
+	const q = `SELECT order_uid, track_number, entry, locale, internal_signature, customer_id,
+	           delivery_service, shardkey, sm_id, date_created
+	           FROM orders WHERE order_uid = ANY($1)`
+	rows, err := r.pool.Query(ctx, q, ids)
+	if err != nil {
+		r.logger(ctx).Errorw("query orders failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[uuid.UUID]*entities.Order, len(ids))
+	for rows.Next() {
+		var o entities.Order
+		if err := rows.Scan(
+			&o.OrderId, &o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSignature,
+			&o.CustomerId, &o.DeliveryService, &o.ShardKey, &o.SmId, &o.DateCreated,
+		); err != nil {
+			return nil, err
+		}
+		out[o.OrderId] = &o
+	}
+	return out, rows.Err()
+}
+
+func (r *Repository) DeliveriesByOrders(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*entities.Delivery, error) {
+	const q = `SELECT order_uid, del_name, phone, zip, city, address, region, email, key_id, dek
+	           FROM deliveries WHERE order_uid = ANY($1)`
+	rows, err := r.pool.Query(ctx, q, ids)
+	if err != nil {
+		r.logger(ctx).Errorw("query deliveries failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[uuid.UUID]*entities.Delivery, len(ids))
+	for rows.Next() {
+		var (
+			id    uuid.UUID
+			d     entities.Delivery
+			keyID *string
+			dek   []byte
+		)
+		if err := rows.Scan(&id, &d.Name, &d.Phone, &d.Zip, &d.City, &d.Address, &d.Region, &d.Email, &keyID, &dek); err != nil {
+			return nil, err
+		}
+		if err := r.open(&d, keyID, dek); err != nil {
+			r.logger(ctx).Errorw("decrypt delivery failed", "order_uid", id, "error", err)
+			return nil, err
+		}
+		out[id] = &d
+	}
+	return out, rows.Err()
+}
+
+func (r *Repository) PaymentsByOrders(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*entities.Payment, error) {
+	const q = `SELECT order_uid, transaction_id, request_id, currency, provider,
+	                  amount::BIGINT, payment_dt, bank, delivery_cost::BIGINT, goods_total::BIGINT, custom_fee::BIGINT
+	           FROM payments WHERE order_uid = ANY($1)`
+	rows, err := r.pool.Query(ctx, q, ids)
+	if err != nil {
+		r.logger(ctx).Errorw("query payments failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[uuid.UUID]*entities.Payment, len(ids))
+	for rows.Next() {
+		var (
+			id uuid.UUID
+			p  entities.Payment
+		)
+		if err := rows.Scan(&id, &p.TransactionId, &p.RequestId, &p.Currency, &p.Provider,
+			&p.Amount, &p.PaymentDt, &p.Bank, &p.DeliveryCost, &p.GoodsTotal, &p.CustomFee); err != nil {
+			return nil, err
+		}
+		out[id] = &p
+	}
+	return out, rows.Err()
+}
+
+func (r *Repository) ItemsByOrders(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]entities.Item, error) {
+	return r.itemsByOrders(ctx, r.pool, ids)
+}
+
+// querier is a pool or a transaction.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func (r *Repository) itemsByOrders(ctx context.Context, db querier, ids []uuid.UUID) (map[uuid.UUID][]entities.Item, error) {
+	const q = `SELECT order_uid, item_id, chrt_id, track_number, price::BIGINT, rid, item_name, sale, item_size,
+	                  total_price::BIGINT, nm_id, brand, status
+	           FROM items WHERE order_uid = ANY($1) ORDER BY order_uid, item_id`
+	rows, err := db.Query(ctx, q, ids)
+	if err != nil {
+		r.logger(ctx).Errorw("query items failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[uuid.UUID][]entities.Item, len(ids))
+	for rows.Next() {
+		var (
+			id uuid.UUID
+			it entities.Item
+		)
+		if err := rows.Scan(&id, &it.ID, &it.ChrtId, &it.TrackNumber, &it.Price, &it.RID, &it.Name, &it.Sale,
+			&it.Size, &it.TotalPrice, &it.NmID, &it.Brand, &it.Status); err != nil {
+			return nil, err
+		}
+		out[id] = append(out[id], it)
+	}
+	return out, rows.Err()
+}
@@ -1,7 +1,9 @@
 package repository
 
 import (
+	"order-service/internal/domain/health"
 	"order-service/internal/domain/repository/cache"
+	"order-service/internal/domain/repository/pii"
 	"order-service/internal/domain/repository/postgres"
 
 	"go.uber.org/fx"
@@ -12,6 +14,13 @@ func Module() fx.Option {
 		fx.Provide(
 			cache.NewRedisCache,    
 			postgres.NewRepository, 
+			pii.NewKeyring,
+			health.AsCheck(func(r *postgres.Repository) health.Check {
+				return health.Check{Name: "postgres", Probe: r.Ping}
+			}),
+			health.AsCheck(func(c *cache.RedisCache) health.Check {
+				return health.Check{Name: "redis", Probe: c.Ping}
+			}),
 		),
 	)
 }
@@ -0,0 +1,95 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// FileRegistry keeps schemas in a JSON file instead of a registry server,
+// for local runs and tests:
+//
+//	{"schemas": [{"id": 1, "subject": "orders-topic-value", "schemaType": "AVRO", "schema": "..."}]}
+//
+// The file is read once; schemas registered later are written back to it.
+type FileRegistry struct {
+	path string
+
+	mu      sync.Mutex
+	entries []fileEntry
+}
+
+type fileEntry struct {
+	Schema
+	Subject string `json:"subject"`
+}
+
+// OpenFile loads path; a missing file is an empty registry.
+func OpenFile(path string) (*FileRegistry, error) {
+	r := &FileRegistry{path: path}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Schemas []fileEntry `json:"schemas"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i := range doc.Schemas {
+		doc.Schemas[i].Type = typeOf(doc.Schemas[i].Schema)
+	}
+	r.entries = doc.Schemas
+	return r, nil
+}
+
+func (r *FileRegistry) ByID(_ context.Context, id int) (Schema, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.entries {
+		if e.ID == id {
+			return e.Schema, nil
+		}
+	}
+	return Schema{}, fmt.Errorf("schema %d: %w", id, ErrNotFound)
+}
+
+func (r *FileRegistry) Register(_ context.Context, subject string, s Schema) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next := 1
+	for _, e := range r.entries {
+		if e.Subject == subject && e.Schema.Schema == s.Schema && e.Type == typeOf(s) {
+			return e.ID, nil
+		}
+		next = max(next, e.ID+1)
+	}
+	s.ID, s.Type = next, typeOf(s)
+	r.entries = append(r.entries, fileEntry{Schema: s, Subject: subject})
+	if err := r.save(); err != nil {
+		r.entries = r.entries[:len(r.entries)-1]
+		return 0, fmt.Errorf("register under %s: %w", subject, err)
+	}
+	return s.ID, nil
+}
+
+func (r *FileRegistry) save() error {
+	b, err := json.MarshalIndent(map[string]any{"schemas": r.entries}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
@@ -0,0 +1,125 @@
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const contentType = "application/vnd.schemaregistry.v1+json"
+
+// httpRegistry speaks the Confluent schema registry REST API. Schemas are
+// immutable per id, so lookups are cached for the life of the process.
+type httpRegistry struct {
+	base   string
+	client *http.Client
+
+	mu     sync.Mutex
+	byID   map[int]Schema
+	latest map[string]int // subject + "\x00" + schema -> id
+}
+
+func newHTTP(base string, timeoutMs int) *httpRegistry {
+	if timeoutMs <= 0 {
+		timeoutMs = 5000
+	}
+	return &httpRegistry{
+		base:   strings.TrimRight(base, "/"),
+		client: &http.Client{Timeout: time.Duration(timeoutMs) * time.Millisecond},
+		byID:   map[int]Schema{},
+		latest: map[string]int{},
+	}
+}
+
+func (r *httpRegistry) ByID(ctx context.Context, id int) (Schema, error) {
+	r.mu.Lock()
+	s, ok := r.byID[id]
+	r.mu.Unlock()
+	if ok {
+		return s, nil
+	}
+
+	if err := r.do(ctx, http.MethodGet, fmt.Sprintf("/schemas/ids/%d", id), nil, &s); err != nil {
+		return Schema{}, fmt.Errorf("schema %d: %w", id, err)
+	}
+	s.ID, s.Type = id, typeOf(s)
+
+	r.mu.Lock()
+	r.byID[id] = s
+	r.mu.Unlock()
+	return s, nil
+}
+
+func (r *httpRegistry) Register(ctx context.Context, subject string, s Schema) (int, error) {
+	key := subject + "\x00" + s.Schema
+	r.mu.Lock()
+	id, ok := r.latest[key]
+	r.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	body := Schema{Schema: s.Schema}
+	if typeOf(s) != TypeAvro {
+		body.Type = s.Type
+	}
+	var resp struct {
+		ID int `json:"id"`
+	}
+	if err := r.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", body, &resp); err != nil {
+		return 0, fmt.Errorf("register under %s: %w", subject, err)
+	}
+
+	r.mu.Lock()
+	r.latest[key] = resp.ID
+	r.mu.Unlock()
+	return resp.ID, nil
+}
+
+func (r *httpRegistry) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, r.base+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", contentType)
+	if in != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// errors come as {"error_code": 40403, "message": "Schema not found"}
+		var e struct {
+			Code    int    `json:"error_code"`
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&e)
+		if resp.StatusCode == http.StatusNotFound {
+			return ErrNotFound
+		}
+		if e.Message == "" {
+			return fmt.Errorf("registry answered %s", resp.Status)
+		}
+		return fmt.Errorf("registry answered %s: %d %s", resp.Status, e.Code, e.Message)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
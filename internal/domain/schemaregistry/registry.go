@@ -0,0 +1,60 @@
+package schemaregistry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"order-service/config"
+)
+
+const (
+	TypeAvro     = "AVRO"
+	TypeProtobuf = "PROTOBUF"
+)
+
+var ErrNotFound = errors.New("schema not found")
+
+// Schema is one registered schema as the Confluent API describes it; an
+// empty Type means AVRO there.
+type Schema struct {
+	ID     int    `json:"id,omitempty"`
+	Type   string `json:"schemaType,omitempty"`
+	Schema string `json:"schema"`
+}
+
+// Registry resolves the schema ids carried by framed messages and
+// registers the schemas of messages produced.
+type Registry interface {
+	ByID(ctx context.Context, id int) (Schema, error)
+	// Register returns the id of s under subject, registering it first if
+	// the subject does not have it yet.
+	Register(ctx context.Context, subject string, s Schema) (int, error)
+}
+
+// New picks the registry by SCHEMA_REGISTRY_URL: an http(s) URL is a
+// Confluent-compatible server, anything else a JSON file. It returns nil
+// when no registry is configured.
+func New(cfg *config.ConfigModel) (Registry, error) {
+	u := cfg.SchemaRegistry.URL
+	switch {
+	case u == "":
+		return nil, nil
+	case strings.HasPrefix(u, "http://"), strings.HasPrefix(u, "https://"):
+		return newHTTP(u, cfg.SchemaRegistry.TimeoutMs), nil
+	default:
+		r, err := OpenFile(strings.TrimPrefix(u, "file://"))
+		if err != nil {
+			return nil, fmt.Errorf("schema registry: %w", err)
+		}
+		return r, nil
+	}
+}
+
+func typeOf(s Schema) string {
+	if s.Type == "" {
+		return TypeAvro
+	}
+	return s.Type
+}
@@ -0,0 +1,111 @@
+package usecase
+
+import (
+	"sync"
+
+	"order-service/internal/domain/entities"
+)
+
+const subscriberQueue = 64
+
+type OrderEvent struct {
+	ID    uint64
+	Order *entities.Order
+}
+
+type Subscription struct {
+	C <-chan OrderEvent
+
+	ch     chan OrderEvent
+	filter func(*entities.Order) bool
+	hub    *eventHub
+}
+
+func (s *Subscription) Close() { s.hub.unsubscribe(s) }
+
+// eventHub fans out saved orders to live subscribers and keeps the last
+// few events in a ring so reconnecting clients can resume.
+type eventHub struct {
+	mu     sync.Mutex
+	nextID uint64
+	ring   []OrderEvent
+	start  int
+	size   int
+	subs   map[*Subscription]struct{}
+}
+
+func newEventHub(capacity int) *eventHub {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+	return &eventHub{
+		nextID: 1,
+		ring:   make([]OrderEvent, capacity),
+		subs:   make(map[*Subscription]struct{}),
+	}
+}
+
+func (h *eventHub) publish(o *entities.Order) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ev := OrderEvent{ID: h.nextID, Order: o}
+	h.nextID++
+
+	if h.size < len(h.ring) {
+		h.ring[(h.start+h.size)%len(h.ring)] = ev
+		h.size++
+	} else {
+		h.ring[h.start] = ev
+		h.start = (h.start + 1) % len(h.ring)
+	}
+
+	for s := range h.subs {
+		if s.filter != nil && !s.filter(o) {
+			continue
+		}
+		select {
+		case s.ch <- ev:
+		default:
+			// slow reader: drop it, the client resumes via Last-Event-ID
+			delete(h.subs, s)
+			close(s.ch)
+		}
+	}
+}
+
+// subscribe registers a subscriber and returns buffered events newer than
+// afterID that pass the filter. Backlog and live delivery are taken under
+// the same lock so no event falls between them.
+func (h *eventHub) subscribe(afterID uint64, filter func(*entities.Order) bool) (*Subscription, []OrderEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var backlog []OrderEvent
+	if afterID > 0 {
+		for i := 0; i < h.size; i++ {
+			ev := h.ring[(h.start+i)%len(h.ring)]
+			if ev.ID <= afterID {
+				continue
+			}
+			if filter != nil && !filter(ev.Order) {
+				continue
+			}
+			backlog = append(backlog, ev)
+		}
+	}
+
+	ch := make(chan OrderEvent, subscriberQueue)
+	s := &Subscription{C: ch, ch: ch, filter: filter, hub: h}
+	h.subs[s] = struct{}{}
+	return s, backlog
+}
+
+func (h *eventHub) unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.ch)
+	}
+}
@@ -2,7 +2,12 @@ package usecase
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"order-service/config"
 	"order-service/internal/domain/entities"
+	"order-service/internal/domain/logctx"
+	"order-service/internal/domain/metrics"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -11,76 +16,240 @@ import (
 type repo interface {
 	Find(ctx context.Context, id string) (*entities.Order, error)
 	Save(ctx context.Context, order *entities.Order) error
+	SaveBatch(ctx context.Context, orders []*entities.Order) error
+	Unchanged(ctx context.Context, orders []*entities.Order) (map[uuid.UUID]bool, error)
 	CacheRestore(ctx context.Context) ([]*entities.Order, error)
 	RecentIDs(ctx context.Context, limit int) ([]uuid.UUID, error)
+	ListIDs(ctx context.Context, f entities.OrderFilter) ([]uuid.UUID, error)
+	FindMany(ctx context.Context, ids []uuid.UUID) ([]*entities.Order, error)
+	OrdersByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*entities.Order, error)
+	DeliveriesByOrders(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*entities.Delivery, error)
+	PaymentsByOrders(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*entities.Payment, error)
+	ItemsByOrders(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]entities.Item, error)
+	Export(ctx context.Context, f entities.OrderFilter, fn func(*entities.Order) error) error
 }
 
 type cache interface {
 	Get(id string) (*entities.Order, bool)
 	Set(id string, order *entities.Order)
+	GetMany(ids []string) map[string]*entities.Order
+	SetMany(orders []*entities.Order)
+	Delete(ctx context.Context, ids []string) error
 }
 
 type OrderUC struct {
-	repo  repo
-	cache cache
-	log   *zap.SugaredLogger
+	repo   repo
+	cache  cache
+	events *eventHub
+	log    *zap.SugaredLogger
 }
 
-func NewOrderUC(r repo, c cache, l *zap.Logger) (*OrderUC, error) {
-	return &OrderUC{repo: r, cache: c, log: l.Named("usecase").Sugar()}, nil
+func NewOrderUC(cfg *config.ConfigModel, r repo, c cache, l *zap.Logger) (*OrderUC, error) {
+	return &OrderUC{
+		repo:   r,
+		cache:  c,
+		events: newEventHub(cfg.Stream.BufferSize),
+		log:    l.Named("usecase").Sugar(),
+	}, nil
 }
 
+// logger tags lines with the request id from ctx.
+func (uc *OrderUC) logger(ctx context.Context) *zap.SugaredLogger { return logctx.From(ctx, uc.log) }
+
 func (uc *OrderUC) Get(ctx context.Context, id string) (*entities.Order, error) {
-	uc.log.Infow("get order", "order_uid", id)
+	uc.logger(ctx).Infow("get order", "order_uid", id)
 
 	if _, err := uuid.Parse(id); err != nil {
-		uc.log.Warnw("invalid uuid", "order_uid", id, "error", err)
-		return nil, err
+		uc.logger(ctx).Warnw("invalid uuid", "order_uid", id, "error", err)
+		return nil, fmt.Errorf("%w: %v", entities.ErrInvalidID, err)
 	}
 
 	if v, ok := uc.cache.Get(id); ok {
-		uc.log.Debugw("served from cache", "order_uid", id)
+		uc.logger(ctx).Debugw("served from cache", "order_uid", id)
 		return v, nil
 	}
 
 	o, err := uc.repo.Find(ctx, id)
 	if err != nil {
-		uc.log.Errorw("db find error", "order_uid", id, "error", err)
-		return nil, err
+		uc.logger(ctx).Errorw("db find error", "order_uid", id, "error", err)
+		return nil, storageErr(err)
 	}
 	if o == nil {
-		uc.log.Infow("not found", "order_uid", id)
-		return nil, nil
+		uc.logger(ctx).Infow("not found", "order_uid", id)
+		return nil, entities.ErrNotFound
 	}
 
 	uc.cache.Set(id, o)
-	uc.log.Debugw("cached after db fetch", "order_uid", id)
+	uc.logger(ctx).Debugw("cached after db fetch", "order_uid", id)
 	return o, nil
 }
 
+// GetMany looks up several orders at once: cache hits in one round trip,
+// the rest with one batched repository call. Orders come back in the order
+// of ids, duplicates once; ids found nowhere are returned as missing.
+func (uc *OrderUC) GetMany(ctx context.Context, ids []string) ([]*entities.Order, []string, error) {
+	uniq := make([]string, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		u, err := uuid.Parse(id)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %q: %v", entities.ErrInvalidID, id, err)
+		}
+		// canonical form, so cache keys and map lookups agree
+		id = u.String()
+		if !seen[id] {
+			seen[id] = true
+			uniq = append(uniq, id)
+		}
+	}
+
+	found := uc.cache.GetMany(uniq)
+	var misses []uuid.UUID
+	for _, id := range uniq {
+		if _, ok := found[id]; !ok {
+			misses = append(misses, uuid.MustParse(id))
+		}
+	}
+	uc.logger(ctx).Infow("get many", "requested", len(uniq), "cache_hits", len(found))
+
+	if len(misses) > 0 {
+		loaded, err := uc.repo.FindMany(ctx, misses)
+		if err != nil {
+			uc.logger(ctx).Errorw("db find many error", "error", err)
+			return nil, nil, storageErr(err)
+		}
+		for _, o := range loaded {
+			found[o.OrderId.String()] = o
+		}
+		uc.cache.SetMany(loaded)
+	}
+
+	out := make([]*entities.Order, 0, len(found))
+	var missing []string
+	for _, id := range uniq {
+		if o, ok := found[id]; ok {
+			out = append(out, o)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	return out, missing, nil
+}
+
 func (uc *OrderUC) Set(ctx context.Context, o *entities.Order) error {
 	id := o.OrderId.String()
-	uc.log.Infow("save order", "order_uid", id)
+	uc.logger(ctx).Infow("save order", "order_uid", id)
 
-	if err := uc.repo.Save(ctx, o); err != nil {
-		uc.log.Errorw("db save error", "order_uid", id, "error", err)
+	if err := o.Validate(); err != nil {
+		uc.logger(ctx).Warnw("invalid order", "order_uid", id, "error", err)
 		return err
 	}
+	if len(uc.dropReplays(ctx, []*entities.Order{o})) == 0 {
+		return nil
+	}
+	if err := uc.repo.Save(ctx, o); err != nil {
+		uc.logger(ctx).Errorw("db save error", "order_uid", id, "error", err)
+		return storageErr(err)
+	}
 	uc.cache.Set(id, o)
-	uc.log.Infow("saved", "order_uid", id)
+	uc.events.publish(o)
+	uc.logger(ctx).Infow("saved", "order_uid", id)
+	return nil
+}
+
+// SetBatch saves orders in one transaction and caches them in one
+// pipeline. Invalid orders are left out and returned; the rest is saved
+// all or nothing. If an id repeats, the later order wins.
+func (uc *OrderUC) SetBatch(ctx context.Context, orders []*entities.Order) (invalid []*entities.Order, err error) {
+	valid := make([]*entities.Order, 0, len(orders))
+	index := make(map[uuid.UUID]int, len(orders))
+	for _, o := range orders {
+		if err := o.Validate(); err != nil {
+			uc.logger(ctx).Warnw("invalid order", "order_uid", o.OrderId.String(), "error", err)
+			invalid = append(invalid, o)
+			continue
+		}
+		if i, ok := index[o.OrderId]; ok {
+			valid[i] = o
+			continue
+		}
+		index[o.OrderId] = len(valid)
+		valid = append(valid, o)
+	}
+	valid = uc.dropReplays(ctx, valid)
+	if len(valid) == 0 {
+		return invalid, nil
+	}
+
+	if err := uc.repo.SaveBatch(ctx, valid); err != nil {
+		uc.logger(ctx).Errorw("db save batch error", "orders", len(valid), "error", err)
+		return invalid, storageErr(err)
+	}
+	uc.cache.SetMany(valid)
+	for _, o := range valid {
+		uc.events.publish(o)
+	}
+	uc.logger(ctx).Infow("saved batch", "orders", len(valid), "invalid", len(invalid))
+	return invalid, nil
+}
+
+// Invalidate drops the cached copies of orders written around the use
+// case, as the bulk import does, so the next read loads them again.
+func (uc *OrderUC) Invalidate(ctx context.Context, ids []uuid.UUID) error {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = id.String()
+	}
+	if err := uc.cache.Delete(ctx, keys); err != nil {
+		uc.logger(ctx).Errorw("cache invalidate failed", "orders", len(ids), "error", err)
+		return err
+	}
 	return nil
 }
 
+// dropReplays leaves out orders stored with the same content already,
+// typically Kafka messages delivered again after a lost commit, so they
+// cost one primary key lookup instead of a rewrite. If the lookup fails
+// everything is kept: saving twice is harmless.
+func (uc *OrderUC) dropReplays(ctx context.Context, orders []*entities.Order) []*entities.Order {
+	same, err := uc.repo.Unchanged(ctx, orders)
+	if err != nil {
+		uc.logger(ctx).Warnw("content hash lookup failed, saving anyway", "orders", len(orders), "error", err)
+		return orders
+	}
+	if len(same) == 0 {
+		return orders
+	}
+
+	fresh := orders[:0:0]
+	for _, o := range orders {
+		if same[o.OrderId] {
+			uc.logger(ctx).Infow("duplicate, skipped", "order_uid", o.OrderId.String())
+			metrics.IngestDuplicates.Inc()
+			continue
+		}
+		fresh = append(fresh, o)
+	}
+	return fresh
+}
+
+// Subscribe delivers every order saved after the call. Events already
+// buffered with ID greater than afterID are returned as backlog; afterID 0
+// means live events only. A nil filter accepts everything.
+func (uc *OrderUC) Subscribe(afterID uint64, filter func(*entities.Order) bool) (*Subscription, []OrderEvent) {
+	return uc.events.subscribe(afterID, filter)
+}
+
 func (uc *OrderUC) WarmCache(ctx context.Context) {
 	list, err := uc.repo.CacheRestore(ctx)
 	if err != nil {
-		uc.log.Errorw("cache restore failed", "error", err)
+		uc.logger(ctx).Errorw("cache restore failed", "error", err)
 		return
 	}
 	for _, o := range list {
 		uc.cache.Set(o.OrderId.String(), o)
 	}
-	uc.log.Infow("cache warmed", "count", len(list))
+	uc.logger(ctx).Infow("cache warmed", "count", len(list))
 }
 
 func (uc *OrderUC) RecentIDs(ctx context.Context, limit int) ([]string, error) {
@@ -89,13 +258,120 @@ func (uc *OrderUC) RecentIDs(ctx context.Context, limit int) ([]string, error) {
 	}
 	ids, err := uc.repo.RecentIDs(ctx, limit)
 	if err != nil {
-		uc.log.Errorw("recent ids error", "error", err)
-		return nil, err
+		uc.logger(ctx).Errorw("recent ids error", "error", err)
+		return nil, storageErr(err)
 	}
 	out := make([]string, 0, len(ids))
 	for _, u := range ids {
 		out = append(out, u.String())
 	}
-	uc.log.Debugw("recent ids", "count", len(out))
+	uc.logger(ctx).Debugw("recent ids", "count", len(out))
 	return out, nil
 }
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// List returns one page of full orders matching f and the cursor of the
+// next page, nil when this is the last one.
+func (uc *OrderUC) List(ctx context.Context, f entities.OrderFilter) ([]*entities.Order, *entities.PageCursor, error) {
+	ids, err := uc.listIDs(ctx, &f)
+	if err != nil {
+		return nil, nil, err
+	}
+	list, err := uc.repo.FindMany(ctx, ids)
+	if err != nil {
+		uc.logger(ctx).Errorw("load orders error", "error", err)
+		return nil, nil, storageErr(err)
+	}
+	return list, nextCursor(list, f.Limit), nil
+}
+
+// ListHeaders is List without delivery, payment and items; callers fetch
+// those in bulk with Deliveries, Payments and Items when they need them.
+func (uc *OrderUC) ListHeaders(ctx context.Context, f entities.OrderFilter) ([]*entities.Order, *entities.PageCursor, error) {
+	ids, err := uc.listIDs(ctx, &f)
+	if err != nil {
+		return nil, nil, err
+	}
+	byID, err := uc.repo.OrdersByIDs(ctx, ids)
+	if err != nil {
+		uc.logger(ctx).Errorw("load order headers error", "error", err)
+		return nil, nil, storageErr(err)
+	}
+	list := make([]*entities.Order, 0, len(ids))
+	for _, id := range ids {
+		if o, ok := byID[id]; ok {
+			list = append(list, o)
+		}
+	}
+	return list, nextCursor(list, f.Limit), nil
+}
+
+func (uc *OrderUC) Deliveries(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*entities.Delivery, error) {
+	m, err := uc.repo.DeliveriesByOrders(ctx, ids)
+	return m, storageErr(err)
+}
+
+func (uc *OrderUC) Payments(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*entities.Payment, error) {
+	m, err := uc.repo.PaymentsByOrders(ctx, ids)
+	return m, storageErr(err)
+}
+
+func (uc *OrderUC) Items(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]entities.Item, error) {
+	m, err := uc.repo.ItemsByOrders(ctx, ids)
+	return m, storageErr(err)
+}
+
+// Export passes every order matching f to fn, oldest first, without going
+// through the cache. An error returned by fn stops the export and comes
+// back unchanged; everything else is a storage error.
+func (uc *OrderUC) Export(ctx context.Context, f entities.OrderFilter, fn func(*entities.Order) error) error {
+	var fnErr error
+	err := uc.repo.Export(ctx, f, func(o *entities.Order) error {
+		fnErr = fn(o)
+		return fnErr
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		uc.logger(ctx).Errorw("export error", "error", err)
+	}
+	return storageErr(err)
+}
+
+func (uc *OrderUC) listIDs(ctx context.Context, f *entities.OrderFilter) ([]uuid.UUID, error) {
+	if f.Limit <= 0 {
+		f.Limit = defaultPageSize
+	}
+	if f.Limit > maxPageSize {
+		f.Limit = maxPageSize
+	}
+	ids, err := uc.repo.ListIDs(ctx, *f)
+	if err != nil {
+		uc.logger(ctx).Errorw("list orders error", "error", err)
+		return nil, storageErr(err)
+	}
+	uc.logger(ctx).Debugw("list orders", "count", len(ids))
+	return ids, nil
+}
+
+func nextCursor(list []*entities.Order, limit int) *entities.PageCursor {
+	if len(list) == 0 || len(list) < limit {
+		return nil
+	}
+	last := list[len(list)-1]
+	return &entities.PageCursor{DateCreated: last.DateCreated, OrderId: last.OrderId}
+}
+
+// storageErr marks repository failures as ErrUnavailable so delivery layers
+// can tell them from bad input. Cancellation by the caller passes through.
+func storageErr(err error) error {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", entities.ErrUnavailable, err)
+}
@@ -0,0 +1,10 @@
+-- Encrypted rows stay ciphertext; the old key file is still needed to read them.
+DROP INDEX IF EXISTS idx_deliveries_key_id;
+DROP INDEX IF EXISTS idx_deliveries_phone_bidx;
+DROP INDEX IF EXISTS idx_deliveries_email_bidx;
+
+ALTER TABLE deliveries
+    DROP COLUMN IF EXISTS phone_bidx,
+    DROP COLUMN IF EXISTS email_bidx,
+    DROP COLUMN IF EXISTS dek,
+    DROP COLUMN IF EXISTS key_id;
@@ -0,0 +1,9 @@
+ALTER TABLE deliveries
+    ADD COLUMN IF NOT EXISTS key_id     TEXT,
+    ADD COLUMN IF NOT EXISTS dek        BYTEA,
+    ADD COLUMN IF NOT EXISTS email_bidx BYTEA,
+    ADD COLUMN IF NOT EXISTS phone_bidx BYTEA;
+
+CREATE INDEX IF NOT EXISTS idx_deliveries_email_bidx ON deliveries(email_bidx);
+CREATE INDEX IF NOT EXISTS idx_deliveries_phone_bidx ON deliveries(phone_bidx);
+CREATE INDEX IF NOT EXISTS idx_deliveries_key_id     ON deliveries(key_id);
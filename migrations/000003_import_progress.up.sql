@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS import_progress (
+    source     TEXT        PRIMARY KEY,
+    line       BIGINT      NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
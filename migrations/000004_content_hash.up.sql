@@ -0,0 +1,3 @@
+-- SHA-256 of the order as last saved; a replayed message with the same
+-- content is skipped instead of rewriting the order and its items.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS content_hash BYTEA;
@@ -0,0 +1,2 @@
+-- the dropped hashes cannot be restored; they are recomputed on save
+SELECT 1;
@@ -0,0 +1,4 @@
+-- content_hash is keyed with the PII index key from now on. Hashes written
+-- before were plain SHA-256 over JSON holding plaintext PII; drop them.
+-- Orders replayed before their next save are rewritten once.
+UPDATE orders SET content_hash = NULL WHERE content_hash IS NOT NULL;